@@ -0,0 +1,40 @@
+package blevebench
+
+import (
+	"github.com/blevesearch/bleve-bench/stats"
+)
+
+// BatchSizeFields are the fields of the values returned by
+// BatchSizeValues, describing the distribution of batch sizes
+var BatchSizeFields = []stats.Field{
+	stats.Int("batch_docs_p50", "docs"),
+	stats.Int("batch_docs_p99", "docs"),
+	stats.Int("batch_docs_max", "docs"),
+	stats.Int("batch_bytes_p50", "bytes"),
+	stats.Int("batch_bytes_p99", "bytes"),
+	stats.Int("batch_bytes_max", "bytes"),
+}
+
+// BatchFull returns true when a batch of docs docs and plainTextBytes
+// bytes of plaintext should be indexed.  When maxBytes > 0 batches are
+// sized by plaintext, capped at maxDocs docs when maxDocs > 0,
+// otherwise they hold batchSize docs.
+func BatchFull(docs int, plainTextBytes uint64, batchSize, maxBytes, maxDocs int) bool {
+	if maxBytes > 0 {
+		return plainTextBytes >= uint64(maxBytes) ||
+			(maxDocs > 0 && docs >= maxDocs)
+	}
+	return docs >= batchSize
+}
+
+// BatchSizeValues returns the values of the BatchSizeFields from
+// histograms of the number of docs and plaintext bytes in each batch
+func BatchSizeValues(docs, bytes *Histogram) []interface{} {
+	if docs.Count() == 0 {
+		return make([]interface{}, len(BatchSizeFields))
+	}
+	return []interface{}{
+		docs.ValueAtQuantile(50), docs.ValueAtQuantile(99), docs.Max(),
+		bytes.ValueAtQuantile(50), bytes.ValueAtQuantile(99), bytes.Max(),
+	}
+}
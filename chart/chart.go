@@ -12,30 +12,25 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// +build debug
-
-package main
+// Package chart provides the script and styles drawing the SVG line
+// charts shared by the bleve-bench report and the live dashboard, so
+// that both pages work offline.
+package chart
 
 import (
-	"fmt"
-	"os"
-
-	"github.com/blevesearch/bleve/index/store"
-	"github.com/blevesearch/bleve/index/store/metrics"
+	_ "embed"
+	"html/template"
 )
 
-func printOtherHeader(s store.KVStore) {
-	storeMetrics, ok := s.(*metrics.Store)
-	if ok && storeMetrics != nil {
-		fmt.Printf(",")
-		storeMetrics.WriteCSVHeader(os.Stdout)
-	}
-}
+//go:embed chart.js
+var script string
+
+//go:embed chart.css
+var style string
+
+// Script defines bbchart.draw, for use in a page's script element
+var Script = template.JS(script)
 
-func printOther(s store.KVStore) {
-	storeMetrics, ok := s.(*metrics.Store)
-	if ok && storeMetrics != nil {
-		fmt.Printf(",")
-		storeMetrics.WriteCSV(os.Stdout)
-	}
-}
+// Style holds the rules for the charts, for use in a page's style
+// element
+var Style = template.CSS(style)
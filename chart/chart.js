@@ -0,0 +1,97 @@
+var bbchart = (function() {
+	var margin = {left: 60, right: 10, top: 10, bottom: 30};
+	var svgNS = "http://www.w3.org/2000/svg";
+
+	function el(name, attrs, parent) {
+		var e = document.createElementNS(svgNS, name);
+		for (var k in attrs) {
+			e.setAttribute(k, attrs[k]);
+		}
+		if (parent) {
+			parent.appendChild(e);
+		}
+		return e;
+	}
+
+	// labels in the style of K/M/B suffixes
+	function fmt(v) {
+		var a = Math.abs(v);
+		if (a >= 1e9) return +(v / 1e9).toPrecision(3) + "B";
+		if (a >= 1e6) return +(v / 1e6).toPrecision(3) + "M";
+		if (a >= 1e3) return +(v / 1e3).toPrecision(3) + "K";
+		return +v.toPrecision(3) + "";
+	}
+
+	// draw appends a chart of the field to the container, with a line
+	// for each series, given as {name, color, points} where the points
+	// are [x, y] pairs.  The options are the width and height, the
+	// xLabel, and whether to mark each point and add a legend naming
+	// the series.
+	function draw(container, field, series, opts) {
+		var xmin = Infinity, xmax = -Infinity, ymin = 0, ymax = -Infinity;
+		series.forEach(function(s) {
+			s.points.forEach(function(p) {
+				xmin = Math.min(xmin, p[0]);
+				xmax = Math.max(xmax, p[0]);
+				ymin = Math.min(ymin, p[1]);
+				ymax = Math.max(ymax, p[1]);
+			});
+		});
+		if (xmin === Infinity) return;
+		if (xmax === xmin) xmax = xmin + 1;
+		if (ymax <= ymin) ymax = ymin + 1;
+
+		var width = opts.width, height = opts.height;
+		var pw = width - margin.left - margin.right;
+		var ph = height - margin.top - margin.bottom;
+		function sx(x) { return margin.left + (x - xmin) / (xmax - xmin) * pw; }
+		function sy(y) { return margin.top + ph - (y - ymin) / (ymax - ymin) * ph; }
+
+		var div = document.createElement("div");
+		div.className = "chart";
+		var h = document.createElement("h3");
+		h.textContent = field.name + (field.unit ? " (" + field.unit + ")" : "");
+		div.appendChild(h);
+
+		var svg = el("svg", {width: width, height: height});
+		for (var t = 0; t <= 4; t++) {
+			var yv = ymin + (ymax - ymin) * t / 4;
+			el("line", {"class": "grid", x1: margin.left, x2: margin.left + pw, y1: sy(yv), y2: sy(yv)}, svg);
+			el("text", {x: margin.left - 4, y: sy(yv) + 3, "text-anchor": "end"}, svg).textContent = fmt(yv);
+			var xv = xmin + (xmax - xmin) * t / 4;
+			el("text", {x: sx(xv), y: margin.top + ph + 14, "text-anchor": "middle"}, svg).textContent = fmt(xv);
+		}
+		el("line", {"class": "axis", x1: margin.left, x2: margin.left, y1: margin.top, y2: margin.top + ph}, svg);
+		el("line", {"class": "axis", x1: margin.left, x2: margin.left + pw, y1: margin.top + ph, y2: margin.top + ph}, svg);
+		el("text", {x: margin.left + pw / 2, y: height - 2, "text-anchor": "middle"}, svg).textContent = opts.xLabel;
+
+		series.forEach(function(s) {
+			var pts = s.points.map(function(p) { return sx(p[0]) + "," + sy(p[1]); }).join(" ");
+			var line = el("polyline", {"class": "series", points: pts, stroke: s.color}, svg);
+			el("title", {}, line).textContent = s.name;
+			if (!opts.markers) return;
+			s.points.forEach(function(p) {
+				var c = el("circle", {cx: sx(p[0]), cy: sy(p[1]), r: 2, fill: s.color}, svg);
+				el("title", {}, c).textContent = s.name + ": " + opts.xLabel + " " + p[0] + ", " + p[1];
+			});
+		});
+		div.appendChild(svg);
+
+		if (opts.legend) {
+			var legend = document.createElement("div");
+			legend.className = "legend";
+			series.forEach(function(s) {
+				var span = document.createElement("span");
+				var swatch = document.createElement("i");
+				swatch.style.background = s.color;
+				span.appendChild(swatch);
+				span.appendChild(document.createTextNode(s.name));
+				legend.appendChild(span);
+			});
+			div.appendChild(legend);
+		}
+		container.appendChild(div);
+	}
+
+	return {draw: draw};
+})();
@@ -17,7 +17,6 @@ package main
 import (
 	_ "expvar"
 	"flag"
-	"fmt"
 	"log"
 	"net/http"
 	_ "net/http/pprof"
@@ -26,13 +25,14 @@ import (
 	"runtime/pprof"
 	"runtime/trace"
 	"strconv"
-	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/blevesearch/bleve"
 	"github.com/blevesearch/bleve-bench"
+	"github.com/blevesearch/bleve-bench/dashboard"
+	"github.com/blevesearch/bleve-bench/stats"
 
 	"github.com/blevesearch/bleve/analysis"
 	_ "github.com/blevesearch/bleve/config"
@@ -51,7 +51,9 @@ var printTime = flag.Duration("printTime", 5*time.Second, "print stats every pri
 var bindHTTP = flag.String("bindHttp", ":1234", "http bind port")
 var count = flag.Int("count", 100000, "total number of documents to process")
 var statsFile = flag.String("statsFile", "", "<stdout>")
+var statsFormat = flag.String("statsFormat", "csv", "format of the stats output: csv, json")
 var traceprofile = flag.String("traceprofile", "", "write trace profile to file")
+var memStats = flag.Bool("memStats", false, "report go runtime memory and GC stats every printTime")
 
 var tokensProduced uint64
 var lastTokensProduced uint64
@@ -60,11 +62,16 @@ var timeStart time.Time
 var timeLast time.Time
 
 var statsWriter = os.Stdout
+var statsEmitter stats.Emitter
+var memSampler = blevebench.NewMemSampler()
 
 func main() {
 	flag.Parse()
 
-	go http.ListenAndServe(*bindHTTP, nil) // For expvar.
+	dash := dashboard.New("bleve-analyzer")
+	http.Handle("/", dash)
+	go http.ListenAndServe(*bindHTTP, nil) // For expvar and the dashboard.
+	blevebench.SetPhase("starting")
 
 	if *statsFile != "" {
 		// create all parents if necessary
@@ -78,6 +85,21 @@ func main() {
 		}
 	}
 
+	fields := outputFields
+	if *memStats {
+		fields = append(fields, blevebench.MemStatsFields...)
+	}
+	var err error
+	statsEmitter, err = stats.NewEmitter(statsWriter, *statsFormat, fields)
+	if err != nil {
+		log.Fatal(err)
+	}
+	statsEmitter, err = dash.Wrap(statsEmitter)
+	if err != nil {
+		log.Fatal(err)
+	}
+	publishVars()
+
 	if *cpuprofile != "" {
 		f, err := os.Create(*cpuprofile)
 		if err != nil {
@@ -105,6 +127,7 @@ func main() {
 		log.Fatal(err)
 	}
 
+	blevebench.SetPhase("analyzing")
 	printHeader()
 	timeStart = time.Now()
 	timeLast = timeStart
@@ -134,18 +157,29 @@ func main() {
 
 	// print final stats
 	printLine()
+	blevebench.SetPhase("done")
+}
 
+// publishVars publishes the counters, the last stats row and the
+// config through expvar
+func publishVars() {
+	statsEmitter = blevebench.PublishRows("stats", statsEmitter)
+	blevebench.PublishConfig()
+	blevebench.PublishCounter("tokens_produced", &tokensProduced)
 }
 
-var outputFields = []string{
-	"date",
-	"tokens",
-	"avg_million_tokens_per_second",
-	"milllion_tokens_per_second",
+var outputFields = []stats.Field{
+	stats.Time("date"),
+	stats.Int("tokens", "tokens"),
+	stats.Float("avg_million_tokens_per_second", "Mtokens/s"),
+	stats.Float("milllion_tokens_per_second", "Mtokens/s"),
 }
 
 func printHeader() {
-	fmt.Fprintf(statsWriter, "%s\n", strings.Join(outputFields, ","))
+	err := statsEmitter.WriteHeader()
+	if err != nil {
+		log.Fatalf("error writing stats header: %v", err)
+	}
 }
 
 func printLine() {
@@ -162,9 +196,15 @@ func printLine() {
 	cumSeconds := float64(cumTimeTaken) / float64(time.Second)
 	curSeconds := float64(curTimeTaken) / float64(time.Second)
 
-	dateNow := timeNow.Format(time.RFC3339)
-	fmt.Fprintf(statsWriter, "%s,%d,%f,%f\n", dateNow, nowTokensProduced,
-		float64(nowTokensProduced/1000000)/cumSeconds, float64(curTokensProduced/1000000)/curSeconds)
+	row := []interface{}{timeNow, nowTokensProduced,
+		float64(nowTokensProduced/1000000) / cumSeconds, float64(curTokensProduced/1000000) / curSeconds}
+	if *memStats {
+		row = append(row, memSampler.Sample()...)
+	}
+	err := statsEmitter.WriteRow(row)
+	if err != nil {
+		log.Fatalf("error writing stats: %v", err)
+	}
 
 	timeLast = timeNow
 	lastTokensProduced = nowTokensProduced
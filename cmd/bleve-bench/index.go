@@ -17,7 +17,6 @@ package main
 import (
 	"flag"
 	"fmt"
-	"html/template"
 	"io/ioutil"
 	"log"
 	"os"
@@ -29,7 +28,9 @@ import (
 
 	"github.com/blevesearch/bleve"
 	"github.com/blevesearch/bleve-bench"
+	"github.com/blevesearch/bleve-bench/stats"
 	_ "github.com/blevesearch/bleve/config"
+	_ "github.com/blevesearch/bleve/index/store/metrics"
 	_ "github.com/blevesearch/bleve/index/store/null"
 )
 
@@ -37,41 +38,81 @@ var config = flag.String("config", "", "configuration file to use")
 var source = flag.String("source", "tmp/enwiki.txt", "wikipedia line file")
 var target = flag.String("target", "bench.bleve", "target index filename")
 var count = flag.Int("count", 100000, "total number of documents to process")
-var batchSize = flag.Int("batch", 100, "batch size")
+var batchSize = flag.Int("batch", 100, "batch size, also the number of docs indexed singly at the start of each level, even with -batchBytes")
+var batchBytes = flag.Int("batchBytes", 0, "when > 0, batches are sized by plaintext bytes instead, flushing once they reach this size")
+var batchMaxDocs = flag.Int("batchMaxDocs", 0, "when > 0, the maximum number of docs in a batch sized by -batchBytes")
 var level = flag.Int("level", 1000, "report level")
 var qrepeat = flag.Int("qrepeat", 5, "query repeat")
 var cpuprofile = flag.String("cpuprofile", "", "write cpu profile to file")
 var memprofile = flag.String("memprofile", "", "write memory profile every level")
+var profileDir = flag.String("profileDir", "", "write cpu, heap, goroutine, block and mutex profiles for every level into a directory per config under this")
+var blockProfileRate = flag.Int("blockProfileRate", 0, "runtime block profile rate used with -profileDir, 0 disables block profiles, which are cumulative over all configs")
+var mutexProfileFraction = flag.Int("mutexProfileFraction", 0, "runtime mutex profile fraction used with -profileDir, 0 disables mutex profiles, which are cumulative over all configs")
 var configDir = flag.String("configdir", "", "directory for configs")
 var doplot = flag.Bool("plot", false, "generate plots/html")
+var plotDir = flag.String("plotDir", ".", "directory to write plots/html to")
+var baseline = flag.String("baseline", "", "config to compare against in the combined configdir report, defaults to the first")
+var queryFile = flag.String("queries", "", "query set file to run at each level")
+var percentiles = flag.Bool("percentiles", false, "report latency percentiles at each level")
+var statsFormat = flag.String("statsFormat", "csv", "format of the stats output: csv, json")
+var indexSize = flag.Bool("indexSize", false, "report the size of the index on disk at each level")
+var indexStats = flag.String("indexStats", "", "index StatsMap values to report at each level: all, or a comma separated list of JSON pointers")
+var memStats = flag.Bool("memStats", false, "report go runtime memory and GC stats at each level")
+var resume = flag.Bool("resume", false, "continue indexing into an existing target, same as -onExisting=resume")
+var onExisting = flag.String("onExisting", "fail", "what to do when the target already exists: fail, delete, resume")
+var keep = flag.Bool("keep", false, "keep the target of each config in configdir mode, instead of deleting it")
+var waitQuiescent = flag.Bool("waitQuiescent", false, "wait for background persistence and merging to finish before running the queries at each level")
+var quiescentTimeout = flag.Duration("quiescentTimeout", 5*time.Minute, "maximum time to wait for the index to become quiescent")
+var reopen = flag.Bool("reopen", false, "close and reopen the index at each level, timing the open and the first query")
+var verify = flag.Bool("verify", false, "verify the contents of the index at each level and at the end")
+var verifySamples = flag.Int("verifySamples", 100, "number of docs sampled to search for by title when verifying")
+var verifySeed = flag.Int64("verifySeed", 1, "seed for sampling the docs to verify")
+var queryClients = flag.Int("queryClients", 0, "number of goroutines running queries continuously while indexing")
+var clientQueries = flag.String("clientQueries", "", "query set file for the query clients, defaults to the -queries set")
+var runDuration = flag.Duration("duration", 0, "stop indexing after this long, when > 0, even if count has not been reached")
+var spaceFactor = flag.Float64("spaceFactor", 4, "estimated index bytes per plaintext byte for the free space check, 0 disables the check")
 
-type Graph struct {
-	Title string
-	Data  string
-}
-
-func doPlot(filename string, v []string) {
+func doPlot(name string, r *Report) {
 	if *doplot {
-		output, err := os.OpenFile(filename, os.O_CREATE|os.O_RDWR, 0666)
-		m := []Graph{
-			{"avg_single_doc_ms", v[0]},
-			{"avg_batched_doc_ms", v[1]},
-			{"query_water_matches", v[2]},
-			{"first_query_water_ms", v[3]},
-		}
-		t, err := template.ParseFiles("result.tmpl")
+		err := writeReport(*plotDir, name+".html", name, []*Report{r}, "")
 		if err != nil {
-			log.Fatalf("error parsing template: %v", err)
+			log.Fatalf("error writing report: %v", err)
 		}
-		t.Execute(output, m)
 	}
 }
 
+// verifyFailures counts the verifications which found a mismatch
+var verifyFailures int
+
 func main() {
 	flag.Parse()
-	var v []string
+	if *resume {
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "onExisting" && f.Value.String() != "resume" {
+				log.Fatalf("-resume cannot be used with -onExisting=%s", f.Value)
+			}
+		})
+		*onExisting = "resume"
+	}
+	if *profileDir != "" && *cpuprofile != "" {
+		log.Fatal("-cpuprofile and -profileDir cannot be used together")
+	}
+	switch *onExisting {
+	case "fail", "delete", "resume":
+	default:
+		log.Fatalf("unknown -onExisting policy '%s', must be one of fail, delete, resume", *onExisting)
+	}
+	var v *Report
 	if *configDir != "" {
 		files, _ := ioutil.ReadDir(*configDir)
+		base := *baseline
+		if base == "" && len(files) > 0 {
+			base = files[0].Name()
+		}
+		if *doplot && !hasConfig(files, base) {
+			log.Fatalf("baseline config '%s' not found in %s", base, *configDir)
+		}
+		var reports []*Report
 		for _, f := range files {
 			var cpu, mem string
 			if f.Name() == "." || f.Name() == ".." {
@@ -83,17 +124,108 @@ func main() {
 			if *memprofile != "" {
 				mem = *memprofile + "_" + f.Name()
 			}
-			v = runConfig(*configDir+"/"+f.Name(), *target+"_"+f.Name(), cpu, mem)
-			doPlot(f.Name()+".html", v)
+			tar := *target + "_" + f.Name()
+			var resumed bool
+			v, resumed = runConfig(*configDir+"/"+f.Name(), tar, cpu, mem)
+			doPlot(f.Name(), v)
+			reports = append(reports, v)
+			if !*keep && !resumed {
+				log.Printf("removing target: %s", tar)
+				err := os.RemoveAll(tar)
+				if err != nil {
+					log.Fatalf("error removing target: %v", err)
+				}
+			}
 			runtime.GC()
 		}
+		if *doplot {
+			name := filepath.Base(*configDir)
+			err := writeReport(*plotDir, name+"-combined.html", name, reports, base)
+			if err != nil {
+				log.Fatalf("error writing combined report: %v", err)
+			}
+		}
 	} else {
-		v = runConfig(*config, *target, *cpuprofile, *memprofile)
-		doPlot(filepath.Base(*config)+".html", v)
+		v, _ = runConfig(*config, *target, *cpuprofile, *memprofile)
+		doPlot(configName(*config), v)
+	}
+
+	if verifyFailures > 0 {
+		log.Printf("verification failed %d times", verifyFailures)
+		os.Exit(1)
 	}
 }
 
-func runConfig(conf string, tar string, cpu string, mem string) []string {
+func hasConfig(files []os.FileInfo, name string) bool {
+	for _, f := range files {
+		if f.Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// prepareTarget applies the -onExisting policy to the target,
+// returning true when an existing target should be resumed
+func prepareTarget(tar string) bool {
+	_, err := os.Stat(tar)
+	if os.IsNotExist(err) {
+		return false
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	switch *onExisting {
+	case "delete":
+		log.Printf("deleting existing target: %s", tar)
+		err = os.RemoveAll(tar)
+		if err != nil {
+			log.Fatalf("error deleting existing target: %v", err)
+		}
+		return false
+	case "resume":
+		return true
+	}
+	log.Fatalf("target '%s' already exists, use -onExisting=delete or -onExisting=resume", tar)
+	return false
+}
+
+// checkFreeSpace estimates the space needed to index count more docs
+// and fails if the filesystem holding the target has less available
+func checkFreeSpace(tar string) {
+	if *spaceFactor <= 0 {
+		return
+	}
+	avgDocBytes, err := blevebench.AverageArticleBytes(*source, 1000)
+	if err != nil {
+		log.Fatalf("error estimating space needed: %v", err)
+	}
+	needed := uint64(avgDocBytes * float64(*count) * *spaceFactor)
+	dir, err := filepath.Abs(tar)
+	if err != nil {
+		log.Fatal(err)
+	}
+	free, err := blevebench.DiskFree(filepath.Dir(dir))
+	if err != nil {
+		log.Printf("skipping free space check: %v", err)
+		return
+	}
+	if free < needed {
+		log.Fatalf("not enough free space for target '%s', estimated %d bytes needed, %d available", tar, needed, free)
+	}
+}
+
+// configName returns the name used to label output for the config file
+func configName(conf string) string {
+	if conf == "" {
+		return "default"
+	}
+	return filepath.Base(conf)
+}
+
+// runConfig indexes the source into tar using the config, returning
+// the report and whether an existing target was resumed
+func runConfig(conf string, tar string, cpu string, mem string) (*Report, bool) {
 	if cpu != "" {
 		f, err := os.Create(cpu)
 		if err != nil {
@@ -113,32 +245,319 @@ func runConfig(conf string, tar string, cpu string, mem string) []string {
 
 	mapping := blevebench.BuildArticleMapping()
 	benchConfig := blevebench.LoadConfigFile(conf)
+	queries := blevebench.LoadQueryFile(*queryFile)
+
+	resumeTarget := prepareTarget(tar)
+	checkFreeSpace(tar)
 
-	fmt.Printf("Using Index Type: %s\n", benchConfig.IndexType)
-	fmt.Printf("Using KV store: %s\n", benchConfig.KVStore)
-	fmt.Printf("Using KV config: %#v\n", benchConfig.KVConfig)
-	index, err := bleve.NewUsing(tar, mapping, benchConfig.IndexType, benchConfig.KVStore, benchConfig.KVConfig)
+	var index bleve.Index
+	var startDocs uint64
+	if resumeTarget {
+		log.Printf("Resuming index: %s", tar)
+		log.Printf("Using KV config: %#v", benchConfig.KVConfig)
+		index, err = bleve.OpenUsing(tar, benchConfig.KVConfig)
+		if err != nil {
+			log.Fatal(err)
+		}
+		startDocs, err = index.DocCount()
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Skipping %d docs already indexed", startDocs)
+	} else {
+		log.Printf("Using Index Type: %s", benchConfig.IndexType)
+		log.Printf("Using KV store: %s", benchConfig.KVStore)
+		log.Printf("Using KV config: %#v", benchConfig.KVConfig)
+		index, err = bleve.NewUsing(tar, mapping, benchConfig.IndexType, benchConfig.KVStore, benchConfig.KVConfig)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	defer func() {
+		// the index may have been reopened
+		index.Close()
+	}()
+	var idxStats *blevebench.IndexStats
+	if *indexStats != "" {
+		idxStats, err = blevebench.NewIndexStats(index, *indexStats)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	fields := []stats.Field{
+		stats.Int("elapsed", "ms"),
+		stats.Int("docs", "docs"),
+		stats.Float("avg_single_doc_ms", "ms"),
+		stats.Float("avg_batched_doc_ms", "ms"),
+	}
+	for _, q := range queries {
+		fields = append(fields,
+			stats.Int("query_"+q.Name+"_matches", "hits"),
+			stats.Float("first_query_"+q.Name+"_ms", "ms"),
+			stats.Float(fmt.Sprintf("avg_repeated%d_query_%s_ms", *qrepeat, q.Name), "ms"))
+	}
+	if *percentiles {
+		fields = append(fields, percentileFields("single_doc")...)
+		fields = append(fields, percentileFields("batch")...)
+		for _, q := range queries {
+			fields = append(fields, percentileFields("query_"+q.Name)...)
+		}
+	}
+	if *batchBytes > 0 {
+		fields = append(fields, blevebench.BatchSizeFields...)
+	}
+	if *indexSize {
+		fields = append(fields, blevebench.IndexSizeFields...)
+	}
+	if *memStats {
+		fields = append(fields, blevebench.MemStatsFields...)
+	}
+	if *waitQuiescent {
+		fields = append(fields, stats.Float("quiescent_wait_ms", "ms"))
+	}
+	if *reopen {
+		fields = append(fields, reopenFields...)
+	}
+	if *verify {
+		fields = append(fields, verifyFields...)
+	}
+	if *queryClients > 0 {
+		fields = append(fields, queryLoadFields()...)
+	}
+	if idxStats != nil {
+		fields = append(fields, idxStats.Fields()...)
+	}
+
+	emitter, err := stats.NewEmitter(os.Stdout, *statsFormat, fields)
 	if err != nil {
 		log.Fatal(err)
 	}
-	_, store, err := index.Advanced()
+	err = emitter.WriteHeader()
 	if err != nil {
 		log.Fatal(err)
 	}
-
-	lines := make([]string, 4)
-	tot := 0
-	// print header
-	fmt.Printf("elapsed,docs,avg_single_doc_ms,avg_batched_doc_ms,query_water_matches,first_query_water_ms,avg_repeated%d_query_water_ms", *qrepeat)
-	printOtherHeader(store)
-	fmt.Printf("\n")
+	report := NewReport(configName(conf), fields)
 
 	singleCount := 0
 	var singleTime time.Duration
 	batchCount := 0
 	var batchTime time.Duration
+	singleHist := blevebench.NewHistogram()
+	batchHist := blevebench.NewHistogram()
+	batchDocsHist := blevebench.NewHistogram()
+	batchBytesHist := blevebench.NewHistogram()
+	queryHists := make([]*blevebench.Histogram, len(queries))
+	for qi := range queries {
+		queryHists[qi] = blevebench.NewHistogram()
+	}
+	memSampler := blevebench.NewMemSampler()
+	var ver *verifier
+	if *verify {
+		ver = newVerifier(*verifySeed, *verifySamples)
+		defer func() {
+			verifyFailures += ver.failures
+		}()
+	}
+
+	// skip the docs already in a resumed index, counting their
+	// plaintext so that size ratios cover the whole index
+	var plainTextBytes uint64
+	for skipped := uint64(0); skipped < startDocs; skipped++ {
+		a, err := wikiReader.Next()
+		if err != nil {
+			log.Fatalf("error skipping indexed docs: %v", err)
+		}
+		plainTextBytes += uint64(len(a.Title) + len(a.Text))
+		if ver != nil {
+			ver.indexed(a.Title)
+		}
+	}
+	if startDocs > 0 {
+		// don't count the skipping in the elapsed time
+		start = time.Now()
+	}
+
+	var load *queryLoad
+	if *queryClients > 0 {
+		loadQueries := queries
+		if *clientQueries != "" {
+			loadQueries = blevebench.LoadQueryFile(*clientQueries)
+		}
+		load = newQueryLoad(index, loadQueries, *queryClients)
+		load.start()
+		defer load.stop()
+	}
+
+	var profiler *levelProfiler
+	if *profileDir != "" {
+		profiler = newLevelProfiler(filepath.Join(*profileDir, configName(conf)), int(startDocs))
+		defer profiler.close()
+	}
+
+	// level numbering continues from the docs already indexed
+	first := int(startDocs) + 1
 	batch := index.NewBatch()
-	for i := 1; i < (*count)+1; i++ {
+	var batchIDs []string
+	var bytesInBatch uint64
+	batchDocs := 0
+
+	flushBatch := func() {
+		batchStart := time.Now()
+		err := index.Batch(batch)
+		if err != nil {
+			log.Fatalf("error executing batch: %v", err)
+		}
+		duration := time.Since(batchStart)
+		if ver != nil {
+			for _, id := range batchIDs {
+				ver.indexed(id)
+			}
+		}
+		batchIDs = batchIDs[:0]
+		batchCount++
+		batchDocs += batch.Size()
+		batchTime += duration
+		batchHist.RecordDuration(duration)
+		batchDocsHist.Record(int64(batch.Size()))
+		batchBytesHist.Record(int64(bytesInBatch))
+		// reset batch
+		batch = index.NewBatch()
+		bytesInBatch = 0
+	}
+
+	reportLevel := func(i int) {
+		// wait for background work to finish
+		var quiescentWait time.Duration
+		if *waitQuiescent {
+			var err error
+			quiescentWait, err = blevebench.WaitQuiescent(index, 10*time.Millisecond, *quiescentTimeout)
+			if err != nil {
+				log.Printf("error waiting for quiescence: %v", err)
+			}
+		}
+
+		// close and reopen the index, the query clients are
+		// blocked until it is open again
+		var reopenTime, reopenQueryTime time.Duration
+		if *reopen {
+			doReopen := func() bleve.Index {
+				var err error
+				index, reopenTime, err = blevebench.Reopen(index, tar, benchConfig.KVConfig)
+				if err != nil {
+					log.Fatalf("error reopening index: %v", err)
+				}
+				return index
+			}
+			if load != nil {
+				load.swap(doReopen)
+			} else {
+				doReopen()
+			}
+			var err error
+			reopenQueryTime, err = blevebench.TimeFirstQuery(index, queries[0])
+			if err != nil {
+				log.Fatalf("error searching reopened index: %v", err)
+			}
+		}
+
+		// run the queries
+		results := make([]queryResult, len(queries))
+		for qi, q := range queries {
+			results[qi] = runQuery(index, q, queryHists[qi])
+		}
+
+		// print stats
+		avgSingleDocTime := float64(singleTime) / float64(singleCount)
+		avgBatchDocTime := float64(batchTime) / float64(batchDocs)
+		elapsedTime := time.Since(start) / time.Millisecond
+		row := []interface{}{int64(elapsedTime), i, avgSingleDocTime / float64(time.Millisecond), avgBatchDocTime / float64(time.Millisecond)}
+		for _, r := range results {
+			row = append(row, r.matches, r.firstQueryTime/float64(time.Millisecond), r.avgQueryTime/float64(time.Millisecond))
+		}
+		if *percentiles {
+			row = append(row, percentileValues(singleHist)...)
+			row = append(row, percentileValues(batchHist)...)
+			for _, h := range queryHists {
+				row = append(row, percentileValues(h)...)
+			}
+		}
+		if *batchBytes > 0 {
+			row = append(row, blevebench.BatchSizeValues(batchDocsHist, batchBytesHist)...)
+		}
+		if *indexSize {
+			sizes, err := blevebench.IndexSizeValues(tar, uint64(i), plainTextBytes)
+			if err != nil {
+				log.Fatalf("error measuring index size: %v", err)
+			}
+			row = append(row, sizes...)
+		}
+		if *memStats {
+			row = append(row, memSampler.Sample()...)
+		}
+		if *waitQuiescent {
+			row = append(row, float64(quiescentWait)/float64(time.Millisecond))
+		}
+		if *reopen {
+			row = append(row, float64(reopenTime)/float64(time.Millisecond),
+				float64(reopenQueryTime)/float64(time.Millisecond))
+		}
+		if ver != nil {
+			row = append(row, ver.check(index)...)
+		}
+		if load != nil {
+			row = append(row, load.values()...)
+		}
+		if idxStats != nil {
+			row = append(row, idxStats.Values(index)...)
+		}
+		err := emitter.WriteRow(row)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *doplot {
+			report.Add(row)
+		}
+
+		// reset stats
+		singleCount = 0
+		singleTime = 0
+		batchCount = 0
+		batchDocs = 0
+		batchTime = 0
+		singleHist.Reset()
+		batchHist.Reset()
+		batchDocsHist.Reset()
+		batchBytesHist.Reset()
+		for _, h := range queryHists {
+			h.Reset()
+		}
+
+		// dump mem stats if requested
+		if mem != "" {
+			f, err := os.Create(strconv.Itoa(i) + "-" + mem)
+			if err != nil {
+				log.Fatal(err)
+			}
+			pprof.WriteHeapProfile(f)
+		}
+		if profiler != nil {
+			profiler.level(i)
+		}
+	}
+
+	var deadline time.Time
+	if *runDuration > 0 {
+		deadline = start.Add(*runDuration)
+	}
+
+	last := first - 1
+	for i := first; i < first+(*count); i++ {
+
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			log.Printf("stopping after %v", *runDuration)
+			break
+		}
 
 		leveli := i % *level
 
@@ -146,95 +565,127 @@ func runConfig(conf string, tar string, cpu string, mem string) []string {
 		if err != nil {
 			log.Fatal(err)
 		}
+		plainTextBytes += uint64(len(a.Title) + len(a.Text))
 		if leveli < *batchSize {
-			// index single
+			// index single, the first -batch docs of each level are
+			// indexed singly even when batches are sized by -batchBytes
 			singleStart := time.Now()
 			err = index.Index(a.Title, a)
 			if err != nil {
 				log.Fatalf("error indexing: %v", err)
 			}
 			duration := time.Since(singleStart)
+			if ver != nil {
+				ver.indexed(a.Title)
+			}
 			singleCount++
 			singleTime += duration
+			singleHist.RecordDuration(duration)
 		} else {
 			// add to batch
 			batch.Index(a.Title, a)
+			batchIDs = append(batchIDs, a.Title)
+			bytesInBatch += uint64(len(a.Title) + len(a.Text))
 			// if batch is full index it
-			if batch.Size() == *batchSize {
-				batchStart := time.Now()
-				err := index.Batch(batch)
-				if err != nil {
-					log.Fatalf("error executing batch: %v", err)
-				}
-				duration := time.Since(batchStart)
-				batchCount++
-				batchTime += duration
-				// reset batch
-				batch = index.NewBatch()
+			if blevebench.BatchFull(batch.Size(), bytesInBatch, *batchSize, *batchBytes, *batchMaxDocs) {
+				flushBatch()
 			}
 		}
+		last = i
 
 		if leveli == 0 {
+			reportLevel(i)
+		}
+	}
 
-			// run some queries
-			termQueryCount := 0
-			termQueryStart := time.Now()
-			termQuery := bleve.NewTermQuery("water")
-			termQuery.SetField("text")
-			termSearch := bleve.NewSearchRequest(termQuery)
-			searchResults, err := index.Search(termSearch)
-			if err != nil {
-				log.Fatalf("error searching: %v", err)
-			}
-			termQueryCount++
-			termQueryTime := time.Since(termQueryStart)
+	// flush the pending batch and report the final partial level
+	if batch.Size() > 0 {
+		flushBatch()
+	}
+	if last >= first && last%*level != 0 {
+		reportLevel(last)
+	}
 
-			firstQueryTime := float64(termQueryTime)
+	if ver != nil {
+		vals := ver.final(index)
+		log.Printf("verify: doc count %d, expected %d, duplicate ids %d, sample failures %d",
+			vals[0], vals[1], vals[2], vals[3])
+	}
 
-			for termQueryCount < *qrepeat {
-				termQueryStart = time.Now()
-				searchResults, err = index.Search(termSearch)
-				if err != nil {
-					log.Fatal(err)
-				}
-				termQueryCount++
-				termQueryTime += time.Since(termQueryStart)
-			}
-
-			// print stats
-			avgSingleDocTime := float64(singleTime) / float64(singleCount)
-			avgBatchTime := float64(batchTime) / float64(batchCount)
-			avgBatchDocTime := float64(avgBatchTime) / float64(*batchSize)
-			avgQueryTime := float64(termQueryTime) / float64(termQueryCount)
-			elapsedTime := time.Since(start) / time.Millisecond
-			fmt.Printf("%d,%d,%f,%f,%d,%f,%f", elapsedTime, i, avgSingleDocTime/float64(time.Millisecond), avgBatchDocTime/float64(time.Millisecond), searchResults.Total, firstQueryTime/float64(time.Millisecond), avgQueryTime/float64(time.Millisecond))
-			printOther(store)
-			if *doplot {
-				lines[0] += fmt.Sprintf("%d,%f\n", i, avgSingleDocTime/float64(time.Millisecond))
-				lines[1] += fmt.Sprintf("%d,%f\n", i, avgBatchDocTime/float64(time.Millisecond))
-				lines[2] += fmt.Sprintf("%d,%f\n", i, firstQueryTime/float64(time.Millisecond))
-				lines[3] += fmt.Sprintf("%d,%f\n", i, avgQueryTime/float64(time.Millisecond))
-				tot++
-			}
-
-			fmt.Printf("\n")
-
-			// reset stats
-			singleCount = 0
-			singleTime = 0
-			batchCount = 0
-			batchTime = 0
-
-			// dump mem stats if requested
-			if mem != "" {
-				f, err := os.Create(strconv.Itoa(i) + "-" + mem)
-				if err != nil {
-					log.Fatal(err)
-				}
-				pprof.WriteHeapProfile(f)
-			}
+	return report, resumeTarget
+}
+
+var reopenFields = []stats.Field{
+	stats.Float("reopen_ms", "ms"),
+	stats.Float("reopen_first_query_ms", "ms"),
+}
+
+type queryResult struct {
+	matches        uint64
+	firstQueryTime float64
+	avgQueryTime   float64
+}
+
+// runQuery runs the query qrepeat times, recording the number of
+// matches, the time taken by the first run and the average time of
+// all runs.  The time taken by each run is also added to hist.
+func runQuery(index bleve.Index, q *blevebench.NamedQuery, hist *blevebench.Histogram) queryResult {
+	search := bleve.NewSearchRequest(q.Query)
+	queryCount := 0
+	queryStart := time.Now()
+	searchResults, err := index.Search(search)
+	if err != nil {
+		log.Fatalf("error searching '%s': %v", q.Name, err)
+	}
+	queryCount++
+	queryTime := time.Since(queryStart)
+	hist.RecordDuration(queryTime)
+
+	firstQueryTime := float64(queryTime)
+
+	for queryCount < *qrepeat {
+		queryStart = time.Now()
+		searchResults, err = index.Search(search)
+		if err != nil {
+			log.Fatal(err)
 		}
+		queryCount++
+		duration := time.Since(queryStart)
+		queryTime += duration
+		hist.RecordDuration(duration)
+	}
+
+	return queryResult{
+		matches:        searchResults.Total,
+		firstQueryTime: firstQueryTime,
+		avgQueryTime:   float64(queryTime) / float64(queryCount),
+	}
+}
+
+// percentileColumns are the quantiles reported, along with the max,
+// when percentiles are enabled
+var percentileColumns = []struct {
+	name     string
+	quantile float64
+}{
+	{"p50", 50},
+	{"p90", 90},
+	{"p99", 99},
+	{"p999", 99.9},
+}
+
+func percentileFields(prefix string) []stats.Field {
+	rv := make([]stats.Field, 0, len(percentileColumns)+1)
+	for _, pc := range percentileColumns {
+		rv = append(rv, stats.Float(prefix+"_"+pc.name+"_ms", "ms"))
+	}
+	return append(rv, stats.Float(prefix+"_max_ms", "ms"))
+}
 
+func percentileValues(h *blevebench.Histogram) []interface{} {
+	rv := make([]interface{}, 0, len(percentileColumns)+1)
+	for _, pc := range percentileColumns {
+		rv = append(rv, float64(h.ValueAtQuantile(pc.quantile))/float64(time.Millisecond))
 	}
-	return lines
+	return append(rv, float64(h.Max())/float64(time.Millisecond))
 }
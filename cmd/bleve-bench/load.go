@@ -0,0 +1,133 @@
+//  Copyright (c) 2019 Couchbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve-bench"
+	"github.com/blevesearch/bleve-bench/stats"
+)
+
+func queryLoadFields() []stats.Field {
+	fields := []stats.Field{
+		stats.Int("load_queries", "queries"),
+		stats.Int("load_query_errors", "queries"),
+		stats.Float("load_qps", "queries/s"),
+	}
+	return append(fields, percentileFields("load_query")...)
+}
+
+// queryLoad runs the queries continuously from a number of client
+// goroutines, while the index is being built, so the latency of
+// searches can be seen under concurrent writes
+type queryLoad struct {
+	indexLock sync.RWMutex
+	index     bleve.Index
+	queries   []*blevebench.NamedQuery
+	clients   int
+
+	m      sync.Mutex
+	hist   *blevebench.Histogram
+	errors uint64
+	since  time.Time
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newQueryLoad(index bleve.Index, queries []*blevebench.NamedQuery, clients int) *queryLoad {
+	return &queryLoad{
+		index:   index,
+		queries: queries,
+		clients: clients,
+		hist:    blevebench.NewHistogram(),
+		done:    make(chan struct{}),
+	}
+}
+
+// start starts the client goroutines
+func (l *queryLoad) start() {
+	l.m.Lock()
+	l.since = time.Now()
+	l.m.Unlock()
+	for c := 0; c < l.clients; c++ {
+		l.wg.Add(1)
+		go l.run(c)
+	}
+}
+
+// stop stops the client goroutines and waits for them to exit
+func (l *queryLoad) stop() {
+	close(l.done)
+	l.wg.Wait()
+}
+
+// swap blocks the clients, waiting for their searches in progress to
+// finish, while fn closes the index and returns the one replacing it
+func (l *queryLoad) swap(fn func() bleve.Index) {
+	l.indexLock.Lock()
+	defer l.indexLock.Unlock()
+	l.index = fn()
+}
+
+func (l *queryLoad) run(client int) {
+	defer l.wg.Done()
+	// each client starts at a different point in the query set
+	i := client
+	for {
+		select {
+		case <-l.done:
+			return
+		default:
+		}
+		q := l.queries[i%len(l.queries)]
+		i++
+		l.indexLock.RLock()
+		queryStart := time.Now()
+		_, err := l.index.Search(bleve.NewSearchRequest(q.Query))
+		l.indexLock.RUnlock()
+		duration := time.Since(queryStart)
+		l.m.Lock()
+		if err != nil {
+			l.errors++
+		} else {
+			l.hist.RecordDuration(duration)
+		}
+		l.m.Unlock()
+	}
+}
+
+// values returns the values of the queryLoadFields for the queries
+// completed since the previous call, and resets them
+func (l *queryLoad) values() []interface{} {
+	l.m.Lock()
+	defer l.m.Unlock()
+	now := time.Now()
+	elapsed := now.Sub(l.since)
+	rv := []interface{}{l.hist.Count(), l.errors,
+		float64(l.hist.Count()) / elapsed.Seconds()}
+	if l.hist.Count() > 0 {
+		rv = append(rv, percentileValues(l.hist)...)
+	} else {
+		rv = append(rv, make([]interface{}, len(percentileColumns)+1)...)
+	}
+	l.hist.Reset()
+	l.errors = 0
+	l.since = now
+	return rv
+}
@@ -0,0 +1,130 @@
+//  Copyright (c) 2019 Couchbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+)
+
+// levelProfiler writes a set of profiles into a directory for each
+// level, named by the number of docs indexed so they sort in order:
+//
+//	0000001000-cpu.pprof        cpu profile for the level
+//	0000001000-heap.pprof       heap profile at the end of the level
+//	0000001000-goroutine.pprof  goroutines at the end of the level
+//	0000001000-block.pprof      blocking profile, when -blockProfileRate > 0
+//	0000001000-mutex.pprof      mutex profile, when -mutexProfileFraction > 0
+//
+// The block and mutex profiles count from the start of the process, so
+// in configdir mode include the earlier configs.  They are also written
+// when profiling starts, named by the docs already indexed, as the base
+// to subtract from the first level.
+type levelProfiler struct {
+	dir     string
+	cpuFile *os.File
+}
+
+const cpuSliceName = "cpu.pprof.tmp"
+
+func newLevelProfiler(dir string, startDocs int) *levelProfiler {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		log.Fatalf("error creating profile dir: %v", err)
+	}
+	runtime.SetBlockProfileRate(*blockProfileRate)
+	runtime.SetMutexProfileFraction(*mutexProfileFraction)
+	p := &levelProfiler{dir: dir}
+	p.writeCumulative(startDocs)
+	p.startCPU()
+	return p
+}
+
+func (p *levelProfiler) startCPU() {
+	var err error
+	p.cpuFile, err = os.Create(filepath.Join(p.dir, cpuSliceName))
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = pprof.StartCPUProfile(p.cpuFile)
+	if err != nil {
+		log.Fatalf("error starting cpu profile: %v", err)
+	}
+}
+
+func (p *levelProfiler) stopCPU() {
+	pprof.StopCPUProfile()
+	err := p.cpuFile.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func (p *levelProfiler) path(docs int, name string) string {
+	return filepath.Join(p.dir, fmt.Sprintf("%010d-%s.pprof", docs, name))
+}
+
+// level ends the cpu profile slice for the level ending at docs, and
+// writes the other profiles, before starting the next slice
+func (p *levelProfiler) level(docs int) {
+	p.stopCPU()
+	err := os.Rename(filepath.Join(p.dir, cpuSliceName), p.path(docs, "cpu"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	p.writeProfile(docs, "heap")
+	p.writeProfile(docs, "goroutine")
+	p.writeCumulative(docs)
+
+	p.startCPU()
+}
+
+// writeCumulative writes the block and mutex profiles, when enabled
+func (p *levelProfiler) writeCumulative(docs int) {
+	if *blockProfileRate > 0 {
+		p.writeProfile(docs, "block")
+	}
+	if *mutexProfileFraction > 0 {
+		p.writeProfile(docs, "mutex")
+	}
+}
+
+func (p *levelProfiler) writeProfile(docs int, name string) {
+	f, err := os.Create(p.path(docs, name))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	err = pprof.Lookup(name).WriteTo(f, 0)
+	if err != nil {
+		log.Fatalf("error writing %s profile: %v", name, err)
+	}
+}
+
+// close stops profiling, discarding the slice after the last level
+func (p *levelProfiler) close() {
+	p.stopCPU()
+	err := os.Remove(filepath.Join(p.dir, cpuSliceName))
+	if err != nil {
+		log.Fatal(err)
+	}
+	runtime.SetBlockProfileRate(0)
+	runtime.SetMutexProfileFraction(0)
+}
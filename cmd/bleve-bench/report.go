@@ -0,0 +1,205 @@
+//  Copyright (c) 2019 Couchbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/blevesearch/bleve-bench/chart"
+	"github.com/blevesearch/bleve-bench/stats"
+)
+
+//go:embed report.tmpl
+var reportTemplate string
+
+// reportXField is the field used for the x axis of every chart
+const reportXField = "docs"
+
+// Report collects the rows emitted for one config so that they can
+// be rendered as charts
+type Report struct {
+	Name   string          `json:"name"`
+	Fields []stats.Field   `json:"fields"`
+	Rows   [][]interface{} `json:"rows"`
+}
+
+// NewReport returns an empty report for the config name, with the
+// same fields as the stats output
+func NewReport(name string, fields []stats.Field) *Report {
+	return &Report{
+		Name:   name,
+		Fields: fields,
+	}
+}
+
+// Add records a row of values, values which cannot be plotted
+// (non-numeric, NaN or Inf) are kept as null
+func (r *Report) Add(values []interface{}) {
+	row := make([]interface{}, len(values))
+	for i, v := range values {
+		switch r.Fields[i].Type {
+		case stats.TypeInt, stats.TypeFloat:
+			row[i] = plotValue(v)
+		}
+	}
+	r.Rows = append(r.Rows, row)
+}
+
+func plotValue(v interface{}) interface{} {
+	var f float64
+	switch v := v.(type) {
+	case int:
+		f = float64(v)
+	case int64:
+		f = float64(v)
+	case uint64:
+		f = float64(v)
+	case float64:
+		f = v
+	default:
+		return nil
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil
+	}
+	return f
+}
+
+// last returns the value of the named field in the final row
+func (r *Report) last(name string) (float64, bool) {
+	if len(r.Rows) < 1 {
+		return 0, false
+	}
+	for i, f := range r.Fields {
+		if f.Name == name {
+			v, ok := r.Rows[len(r.Rows)-1][i].(float64)
+			return v, ok
+		}
+	}
+	return 0, false
+}
+
+// SummaryRow holds the final value of a field in every report, along
+// with the percentage difference from the baseline report
+type SummaryRow struct {
+	Field stats.Field
+	Cells []SummaryCell
+}
+
+type SummaryCell struct {
+	Value string
+	Diff  string
+}
+
+// Summary compares the final row of every report against baseline
+type Summary struct {
+	Baseline string
+	Names    []string
+	Rows     []SummaryRow
+}
+
+// summarize builds a summary of the final values of every numeric
+// field, compared against the report named baseline
+func summarize(reports []*Report, baseline string) *Summary {
+	var base *Report
+	rv := &Summary{Baseline: baseline}
+	for _, r := range reports {
+		rv.Names = append(rv.Names, r.Name)
+		if r.Name == baseline {
+			base = r
+		}
+	}
+	seen := map[string]struct{}{}
+	for _, r := range reports {
+		for _, f := range r.Fields {
+			if _, exists := seen[f.Name]; exists {
+				continue
+			}
+			if f.Type != stats.TypeInt && f.Type != stats.TypeFloat {
+				continue
+			}
+			seen[f.Name] = struct{}{}
+			row := SummaryRow{Field: f}
+			baseVal, baseOk := 0.0, false
+			if base != nil {
+				baseVal, baseOk = base.last(f.Name)
+			}
+			for _, other := range reports {
+				var cell SummaryCell
+				v, ok := other.last(f.Name)
+				if ok {
+					cell.Value = fmt.Sprintf("%.6g", v)
+					if baseOk && baseVal != 0 && other != base {
+						cell.Diff = fmt.Sprintf("%+.1f%%", (v-baseVal)/math.Abs(baseVal)*100)
+					}
+				}
+				row.Cells = append(row.Cells, cell)
+			}
+			rv.Rows = append(rv.Rows, row)
+		}
+	}
+	return rv
+}
+
+type reportData struct {
+	Title   string    `json:"title"`
+	XField  string    `json:"x"`
+	Reports []*Report `json:"reports"`
+	Summary *Summary  `json:"-"`
+
+	ChartScript template.JS  `json:"-"`
+	ChartStyle  template.CSS `json:"-"`
+}
+
+// writeReport renders the reports as a self-contained HTML file
+// named filename in the directory dir.  When there is more than one
+// report each chart overlays all of them, and a summary table
+// compares their final values against the report named baseline.
+func writeReport(dir, filename, title string, reports []*Report, baseline string) error {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+	t, err := template.New("report").Parse(reportTemplate)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(filepath.Join(dir, filename))
+	if err != nil {
+		return err
+	}
+	data := reportData{
+		Title:   title,
+		XField:  reportXField,
+		Reports: reports,
+
+		ChartScript: chart.Script,
+		ChartStyle:  chart.Style,
+	}
+	if len(reports) > 1 {
+		data.Summary = summarize(reports, baseline)
+	}
+	err = t.Execute(f, data)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
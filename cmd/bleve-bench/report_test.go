@@ -0,0 +1,49 @@
+//  Copyright (c) 2019 Couchbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/blevesearch/bleve-bench/stats"
+)
+
+func TestSummarize(t *testing.T) {
+	fields := []stats.Field{
+		stats.Int("docs", "docs"),
+		stats.Float("avg_batched_doc_ms", "ms"),
+	}
+	a := NewReport("a", fields)
+	a.Add([]interface{}{1000, 2.0})
+	a.Add([]interface{}{2000, 4.0})
+	b := NewReport("b", fields)
+	b.Add([]interface{}{1000, 1.0})
+	b.Add([]interface{}{2000, 5.0})
+
+	summary := summarize([]*Report{a, b}, "a")
+	if len(summary.Rows) != 2 {
+		t.Fatalf("expected 2 summary rows, got %d", len(summary.Rows))
+	}
+	row := summary.Rows[1]
+	if row.Field.Name != "avg_batched_doc_ms" {
+		t.Errorf("expected avg_batched_doc_ms, got %s", row.Field.Name)
+	}
+	if row.Cells[0].Value != "4" || row.Cells[0].Diff != "" {
+		t.Errorf("unexpected baseline cell %+v", row.Cells[0])
+	}
+	if row.Cells[1].Value != "5" || row.Cells[1].Diff != "+25.0%" {
+		t.Errorf("unexpected cell %+v", row.Cells[1])
+	}
+}
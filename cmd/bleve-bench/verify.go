@@ -0,0 +1,120 @@
+//  Copyright (c) 2019 Couchbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"log"
+	"math/rand"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve-bench/stats"
+)
+
+var verifyFields = []stats.Field{
+	stats.Int("verify_doc_count", "docs"),
+	stats.Int("verify_expected_docs", "docs"),
+	stats.Int("verify_duplicate_ids", "ids"),
+	stats.Int("verify_sample_failures", "docs"),
+}
+
+// verifier tracks the doc IDs indexed, and a seeded random sample of
+// them, to check the contents of the index
+type verifier struct {
+	ids        map[string]struct{}
+	duplicates uint64
+	sample     []string
+	rng        *rand.Rand
+	samples    int
+	failures   int
+
+	// the values of the last check, unless docs have been indexed since
+	last []interface{}
+}
+
+func newVerifier(seed int64, samples int) *verifier {
+	return &verifier{
+		ids:     make(map[string]struct{}),
+		rng:     rand.New(rand.NewSource(seed)),
+		samples: samples,
+	}
+}
+
+// indexed records that the doc with the id has been indexed
+func (v *verifier) indexed(id string) {
+	v.last = nil
+	if _, exists := v.ids[id]; exists {
+		v.duplicates++
+		return
+	}
+	v.ids[id] = struct{}{}
+
+	// reservoir sample the unique ids
+	if len(v.sample) < v.samples {
+		v.sample = append(v.sample, id)
+		return
+	}
+	j := v.rng.Intn(len(v.ids))
+	if j < v.samples {
+		v.sample[j] = id
+	}
+}
+
+// check compares the index against the docs recorded, returning the
+// values of the verifyFields.  Each sampled doc is searched for by
+// its exact title, which is also its id.
+func (v *verifier) check(index bleve.Index) []interface{} {
+	docCount, err := index.DocCount()
+	if err != nil {
+		log.Fatalf("error verifying doc count: %v", err)
+	}
+	expected := uint64(len(v.ids))
+	ok := docCount == expected
+	if !ok {
+		log.Printf("verify: doc count %d, expected %d", docCount, expected)
+	}
+
+	sampleFailures := 0
+	for _, id := range v.sample {
+		q := bleve.NewTermQuery(id)
+		q.SetField("title")
+		res, err := index.Search(bleve.NewSearchRequest(q))
+		if err != nil {
+			log.Fatalf("error verifying doc '%s': %v", id, err)
+		}
+		found := false
+		for _, hit := range res.Hits {
+			found = found || hit.ID == id
+		}
+		if !found {
+			log.Printf("verify: doc '%s' not found by title", id)
+			sampleFailures++
+		}
+	}
+	if !ok || sampleFailures > 0 {
+		v.failures++
+	}
+
+	v.last = []interface{}{docCount, expected, v.duplicates, sampleFailures}
+	return v.last
+}
+
+// final returns the values of the verifyFields at the end, checking
+// the index again only if docs have been indexed since the last check
+func (v *verifier) final(index bleve.Index) []interface{} {
+	if v.last != nil {
+		return v.last
+	}
+	return v.check(index)
+}
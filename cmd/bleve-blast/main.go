@@ -17,7 +17,6 @@ package main
 import (
 	_ "expvar"
 	"flag"
-	"fmt"
 	"log"
 	"net/http"
 	_ "net/http/pprof"
@@ -26,15 +25,14 @@ import (
 	"runtime/pprof"
 	"runtime/trace"
 	"strconv"
-	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
-	"github.com/dustin/go-jsonpointer"
-
 	"github.com/blevesearch/bleve"
 	"github.com/blevesearch/bleve-bench"
+	"github.com/blevesearch/bleve-bench/dashboard"
+	"github.com/blevesearch/bleve-bench/stats"
 
 	_ "github.com/blevesearch/bleve/config"
 	_ "github.com/blevesearch/bleve/index/store/metrics"
@@ -45,8 +43,11 @@ var config = flag.String("config", "", "configuration file to use")
 var source = flag.String("source", "../../tmp/enwiki.txt", "wikipedia line file")
 var target = flag.String("target", "bench.bleve", "target index filename")
 var count = flag.Int("count", 100000, "total number of documents to process")
+var runDuration = flag.Duration("duration", 0, "stop reading documents after this long, when > 0, even if count has not been reached")
 var maxTextSize = flag.Int("maxTextSize", 0, "when > 0, text is clipped to this length")
 var batchSize = flag.Int("batch", 100, "batch size")
+var batchBytes = flag.Int("batchBytes", 0, "when > 0, batches are sized by plaintext bytes instead, flushing once they reach this size")
+var batchMaxDocs = flag.Int("batchMaxDocs", 0, "when > 0, the maximum number of docs in a batch sized by -batchBytes")
 var cpuprofile = flag.String("cpuprofile", "", "write cpu profile to file")
 var memprofile = flag.String("memprofile", "", "write memory profile at end")
 var numIndexers = flag.Int("numIndexers", 8, "number of indexing goroutines")
@@ -55,23 +56,62 @@ var readerQueueSize = flag.Int("readerQueueSize", 8, "size of queue output from
 var printTime = flag.Duration("printTime", 5*time.Second, "print stats every printTime")
 var bindHttp = flag.String("bindHttp", ":1234", "http bind port")
 var statsFile = flag.String("statsFile", "", "<stdout>")
+var statsFormat = flag.String("statsFormat", "csv", "format of the stats output: csv, json")
 var waitPersist = flag.Bool("waitPersist", false, "wait for all data to be persisted before closing")
 var traceprofile = flag.String("traceprofile", "", "write trace profile to file")
+var indexStats = flag.String("indexStats", "", "index StatsMap values to report every printTime: all, or a comma separated list of JSON pointers")
+var memStats = flag.Bool("memStats", false, "report go runtime memory and GC stats every printTime")
+var probeEvery = flag.Int("probeEvery", 0, "tag every probeEvery'th doc with a marker term and measure how long until search finds it, 0 disables")
+var probeInterval = flag.Duration("probeInterval", time.Millisecond, "how often to search for the pending probe docs")
+var probeTimeout = flag.Duration("probeTimeout", 30*time.Second, "give up on a probe doc not found after this long")
+var docsPerSecond = flag.Float64("docsPerSecond", 0, "limit the ingest rate to this many docs per second, 0 is unlimited")
+var bytesPerSecond = flag.Float64("bytesPerSecond", 0, "limit the ingest rate to this many plaintext bytes per second, 0 is unlimited")
+var workload = flag.String("mix", "", "workload mix of inserts, updates and deletes, eg insert:70,update:25,delete:5, all inserts when empty")
+var mixSeed = flag.Int64("mixSeed", 1, "seed for choosing the operations and IDs of the workload mix")
+var batchLatency = flag.Bool("batchLatency", false, "report the latency of the indexing calls every printTime")
+var stallThreshold = flag.Duration("stallThreshold", time.Second, "indexing calls taking longer than this are counted as stalls")
+var reopen = flag.Bool("reopen", false, "at the end, close and reopen the index, timing the open and the first query")
+var pipelineStats = flag.Bool("pipelineStats", false, "report the work queue depth, and the time the reader and indexers spend blocked, every printTime, and the bottleneck at the end")
+var indexSize = flag.Bool("indexSize", false, "report the size of the index on disk every printTime")
 
 var totalIndexed uint64
 var lastTotalIndexed uint64
 var totalPlainTextIndexed uint64
+var totalInserted uint64
+var totalUpdated uint64
+var totalDeleted uint64
 var lastTotalPlainTextIndexed uint64
 
 var timeStart time.Time
 var timeLast time.Time
 
 var statsWriter = os.Stdout
+var statsEmitter stats.Emitter
+var memSampler = blevebench.NewMemSampler()
+var batchHist = blevebench.NewConcurrentHistogram()
+var limiter *blevebench.RateLimiter
+var mix *workloadMix
+var interrupt *blevebench.Interrupt
+var batchStalls uint64
+var batchDocsHist = blevebench.NewConcurrentHistogram()
+var batchBytesHist = blevebench.NewConcurrentHistogram()
+var index bleve.Index
+var publishedIndex *blevebench.PublishedIndex
+var idxStats *blevebench.IndexStats
+var probes *prober
 
 func main() {
 	flag.Parse()
 
-	go http.ListenAndServe(*bindHttp, nil) // For expvar.
+	// deferred first, so the profiles are stopped and flushed before
+	// exiting with the signal status
+	interrupt = blevebench.HandleInterrupts()
+	defer interrupt.ExitIfInterrupted()
+
+	dash := dashboard.New("bleve-blast")
+	http.Handle("/", dash)
+	go http.ListenAndServe(*bindHttp, nil) // For expvar and the dashboard.
+	blevebench.SetPhase("starting")
 
 	if *statsFile != "" {
 		// create all parents if necessary
@@ -105,22 +145,95 @@ func main() {
 		defer trace.Stop()
 	}
 
+	switch {
+	case *docsPerSecond > 0 && *bytesPerSecond > 0:
+		log.Fatal("only one of -docsPerSecond and -bytesPerSecond may be used")
+	case *docsPerSecond > 0:
+		limiter = blevebench.NewRateLimiter(*docsPerSecond, *docsPerSecond/10)
+	case *bytesPerSecond > 0:
+		limiter = blevebench.NewRateLimiter(*bytesPerSecond, *bytesPerSecond/10)
+	}
+
+	var err error
+	mix, err = parseWorkloadMix()
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	bleve.Config.SetAnalysisQueueSize(*numAnalyzers)
 
 	mapping := blevebench.BuildArticleMapping()
 	benchConfig := blevebench.LoadConfigFile(*config)
 
-	fmt.Printf("Using Index Type: %s\n", benchConfig.IndexType)
-	fmt.Printf("Using KV store: %s\n", benchConfig.KVStore)
-	fmt.Printf("Using KV config: %#v\n", benchConfig.KVConfig)
-	index, err := bleve.NewUsing(*target, mapping, benchConfig.IndexType, benchConfig.KVStore, benchConfig.KVConfig)
+	log.Printf("Using Index Type: %s", benchConfig.IndexType)
+	log.Printf("Using KV store: %s", benchConfig.KVStore)
+	log.Printf("Using KV config: %#v", benchConfig.KVConfig)
+	index, err = bleve.NewUsing(*target, mapping, benchConfig.IndexType, benchConfig.KVStore, benchConfig.KVConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fields := outputFields
+	if *indexSize {
+		fields = append(fields, blevebench.IndexSizeFields...)
+	}
+	if *pipelineStats {
+		fields = append(fields, pipelineFields...)
+	}
+	if *batchLatency {
+		fields = append(fields, batchLatencyFields...)
+	}
+	if *batchBytes > 0 {
+		fields = append(fields, blevebench.BatchSizeFields...)
+	}
+	if limiter != nil {
+		fields = append(fields, rateFields()...)
+	}
+	if *workload != "" {
+		fields = append(fields, mixFields...)
+	}
+	if *memStats {
+		fields = append(fields, blevebench.MemStatsFields...)
+	}
+	if *probeEvery > 0 {
+		fields = append(fields, probeFields...)
+	}
+	if *indexStats != "" {
+		idxStats, err = blevebench.NewIndexStats(index, *indexStats)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fields = append(fields, idxStats.Fields()...)
+	}
+	if *reopen {
+		fields = append(fields, reopenFields...)
+	}
+	statsEmitter, err = stats.NewEmitter(statsWriter, *statsFormat, fields)
 	if err != nil {
 		log.Fatal(err)
 	}
+	statsEmitter, err = dash.Wrap(statsEmitter)
+	if err != nil {
+		log.Fatal(err)
+	}
+	publishVars()
+
+	if *probeEvery > 0 {
+		probes = newProber(index, *probeInterval, *probeTimeout)
+		go probes.run()
+		if mix != nil {
+			// overwriting a probe doc would remove its marker
+			mix.busy = probes.waiting
+		}
+	}
 
+	blevebench.SetPhase("indexing")
 	printHeader()
 	timeStart = time.Now()
 	timeLast = timeStart
+	if *runDuration > 0 {
+		deadline = timeStart.Add(*runDuration)
+	}
 	printLine()
 
 	work := make(chan *Work, *readerQueueSize)
@@ -145,64 +258,152 @@ func main() {
 
 	wg.Wait()
 
-	// print final stats
+	if probes != nil {
+		probes.close()
+	}
+
+	// print final stats, once the print time worker is not printing
+	stopPrintTimeWorker()
 	printLine()
+	if probes != nil {
+		probes.summary()
+	}
+	if *pipelineStats {
+		log.Print(pipeline.verdict(index, time.Since(timeStart)))
+	}
 
 	if *waitPersist {
-		db, err := getDirtyBytes(index)
+		blevebench.SetPhase("waiting for persistence")
+		db, err := blevebench.DirtyBytes(index)
 		for err == nil && db > 0 {
 			time.Sleep(1 * time.Second)
-			db, err = getDirtyBytes(index)
+			db, err = blevebench.DirtyBytes(index)
 		}
 		if err != nil {
 			log.Fatal(err)
 		}
 	}
 
-	index.Close()
+	if *reopen {
+		blevebench.SetPhase("reopening")
+		reopenIndex(benchConfig.KVConfig)
+		// print the reopen stats
+		printLine()
+	}
+
+	publishedIndex.Close()
+	blevebench.SetPhase("done")
 }
 
-func getDirtyBytes(index bleve.Index) (uint64, error) {
-	statsMap := index.StatsMap()
-	keys, err := jsonpointer.ReflectListPointers(statsMap)
+// publishVars publishes the counters, the last stats row, the config
+// and the index stats through expvar
+func publishVars() {
+	statsEmitter = blevebench.PublishRows("stats", statsEmitter)
+	blevebench.PublishConfig()
+	blevebench.PublishCounter("docs_indexed", &totalIndexed)
+	blevebench.PublishCounter("plaintext_bytes_indexed", &totalPlainTextIndexed)
+	blevebench.PublishCounter("inserts", &totalInserted)
+	blevebench.PublishCounter("updates", &totalUpdated)
+	blevebench.PublishCounter("deletes", &totalDeleted)
+	blevebench.PublishCounter("batch_stalls", &batchStalls)
+	publishedIndex = blevebench.PublishIndexStats(index)
+}
+
+var reopenFields = []stats.Field{
+	stats.Float("reopen_ms", "ms"),
+	stats.Float("reopen_first_query_ms", "ms"),
+}
+
+// reopenValues holds the values of the reopenFields, once measured
+var reopenValues []interface{}
+
+// reopenIndex closes and reopens the index, timing the open and the
+// first query against the reopened index
+func reopenIndex(runtimeConfig map[string]interface{}) {
+	var reopenTime time.Duration
+	var err error
+	index, reopenTime, err = publishedIndex.Reopen(*target, runtimeConfig)
 	if err != nil {
-		return 0, err
+		log.Fatalf("error reopening index: %v", err)
 	}
-	for _, key := range keys {
-		if strings.HasSuffix(key, "CurDirtyBytes") {
-			val := jsonpointer.Reflect(statsMap, key)
-			if v, ok := val.(uint64); ok {
-				return v, nil
-			}
-		}
+	queryTime, err := blevebench.TimeFirstQuery(index, blevebench.DefaultQueries()[0])
+	if err != nil {
+		log.Fatalf("error searching reopened index: %v", err)
+	}
+	reopenValues = []interface{}{
+		float64(reopenTime) / float64(time.Millisecond),
+		float64(queryTime) / float64(time.Millisecond),
 	}
-	return 0, nil
+}
+
+// readingDone is when the reading worker finished, in unix nanoseconds,
+// zero while it is reading
+var readingDone int64
+
+// deadline is when reading stops, zero when there is no -duration
+var deadline time.Time
+
+// stopReading returns true once no more documents should be read,
+// at the deadline or once interrupted
+func stopReading() bool {
+	return interrupt.Interrupted() || pastDeadline()
+}
+
+func pastDeadline() bool {
+	return !deadline.IsZero() && time.Now().After(deadline)
 }
 
 type Work struct {
 	batch          *bleve.Batch
-	doc            *blevebench.Article
+	doc            interface{}
 	id             string
 	plainTextBytes uint64
+	probeIDs       []string
+	probeMarkers   []string
+	delete         bool
+	// counts of the operations by type, indexed by op
+	counts [3]int
 }
 
+var printStop = make(chan struct{})
+var printStopped = make(chan struct{})
+
 func printTimeWorker() {
-	tickChan := time.NewTicker(*printTime).C
-	for range tickChan {
-		printLine()
+	defer close(printStopped)
+	ticker := time.NewTicker(*printTime)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			printLine()
+		case <-printStop:
+			return
+		}
 	}
 }
 
-var outputFields = []string{
-	"date",
-	"docs_indexed",
-	"plaintext_bytes_indexed",
-	"avg_mb_per_second",
-	"mb_per_second",
+// stopPrintTimeWorker stops the print time worker, waiting for any
+// line it is printing to finish
+func stopPrintTimeWorker() {
+	if *printTime > 0 {
+		close(printStop)
+		<-printStopped
+	}
+}
+
+var outputFields = []stats.Field{
+	stats.Time("date"),
+	stats.Int("docs_indexed", "docs"),
+	stats.Int("plaintext_bytes_indexed", "bytes"),
+	stats.Float("avg_mb_per_second", "MB/s"),
+	stats.Float("mb_per_second", "MB/s"),
 }
 
 func printHeader() {
-	fmt.Fprintf(statsWriter, "%s\n", strings.Join(outputFields, ","))
+	err := statsEmitter.WriteHeader()
+	if err != nil {
+		log.Fatalf("error writing stats header: %v", err)
+	}
 }
 
 func printLine() {
@@ -223,15 +424,131 @@ func printLine() {
 	cumSeconds := float64(cumTimeTaken) / float64(time.Second)
 	curSeconds := float64(curTimeTaken) / float64(time.Second)
 
-	dateNow := timeNow.Format(time.RFC3339)
-	fmt.Fprintf(statsWriter, "%s,%d,%d,%f,%f\n", dateNow, nowTotalIndexed,
-		nowTotalPlainTextIndexed, cumMBytes/cumSeconds, curMBytes/curSeconds)
+	row := []interface{}{timeNow, nowTotalIndexed,
+		nowTotalPlainTextIndexed, cumMBytes / cumSeconds, curMBytes / curSeconds}
+	if *indexSize {
+		sizes, err := blevebench.IndexSizeValues(*target, nowTotalIndexed, nowTotalPlainTextIndexed)
+		if err != nil {
+			log.Fatalf("error measuring index size: %v", err)
+		}
+		row = append(row, sizes...)
+	}
+	if *pipelineStats {
+		row = append(row, pipeline.values(curTimeTaken)...)
+	}
+	if *batchLatency {
+		row = append(row, batchLatencyValues()...)
+	}
+	if *batchBytes > 0 {
+		row = append(row, blevebench.BatchSizeValues(batchDocsHist.Swap(), batchBytesHist.Swap())...)
+	}
+	if limiter != nil {
+		row = append(row, rateValues(nowTotalIndexed, nowTotalPlainTextIndexed, cumSeconds, curSeconds)...)
+	}
+	if *workload != "" {
+		row = append(row, atomic.LoadUint64(&totalInserted),
+			atomic.LoadUint64(&totalUpdated), atomic.LoadUint64(&totalDeleted))
+	}
+	if *memStats {
+		row = append(row, memSampler.Sample()...)
+	}
+	if probes != nil {
+		row = append(row, probes.values()...)
+	}
+	if idxStats != nil {
+		row = append(row, idxStats.Values(index)...)
+	}
+	if *reopen {
+		if reopenValues != nil {
+			row = append(row, reopenValues...)
+		} else {
+			row = append(row, nil, nil)
+		}
+	}
+	err := statsEmitter.WriteRow(row)
+	if err != nil {
+		log.Fatalf("error writing stats: %v", err)
+	}
 
 	timeLast = timeNow
 	lastTotalIndexed = nowTotalIndexed
 	lastTotalPlainTextIndexed = nowTotalPlainTextIndexed
 }
 
+// throttle waits until the work may be sent to the indexers, when the
+// ingest rate is limited, or until interrupted
+func throttle(docs int, plainTextBytes uint64) {
+	if limiter == nil {
+		return
+	}
+	if *docsPerSecond > 0 {
+		limiter.Wait(float64(docs), interrupt.Done())
+	} else {
+		limiter.Wait(float64(plainTextBytes), interrupt.Done())
+	}
+}
+
+func rateFields() []stats.Field {
+	unit := "docs"
+	if *docsPerSecond <= 0 {
+		unit = "bytes"
+	}
+	return []stats.Field{
+		stats.Float("target_rate", unit+"/s"),
+		stats.Float("actual_rate", unit+"/s"),
+		stats.Float("rate_deficit", unit),
+		stats.Float("rate_lag_seconds", "s"),
+	}
+}
+
+// rateValues returns the values of the rateFields, in docs or bytes
+// as limited.  The deficit is how far indexing has fallen behind the
+// target rate since the start, and the lag is how long the deficit
+// would take to index at that rate.  The target stops growing once the
+// reading worker has finished.
+func rateValues(docs, plainTextBytes uint64, cumSeconds, curSeconds float64) []interface{} {
+	if done := atomic.LoadInt64(&readingDone); done != 0 {
+		cumSeconds = time.Unix(0, done).Sub(timeStart).Seconds()
+	}
+	target := *docsPerSecond
+	actual := docs
+	last := lastTotalIndexed
+	if target <= 0 {
+		target = *bytesPerSecond
+		actual = plainTextBytes
+		last = lastTotalPlainTextIndexed
+	}
+	deficit := target*cumSeconds - float64(actual)
+	if deficit < 0 {
+		deficit = 0
+	}
+	return []interface{}{target, float64(actual-last) / curSeconds,
+		deficit, deficit / target}
+}
+
+var batchLatencyFields = []stats.Field{
+	stats.Int("batches", "batches"),
+	stats.Float("batch_p50_ms", "ms"),
+	stats.Float("batch_p99_ms", "ms"),
+	stats.Float("batch_max_ms", "ms"),
+	stats.Int("batch_stalls", "batches"),
+}
+
+// batchLatencyValues returns the values of the batchLatencyFields for
+// the indexing calls since the previous call
+func batchLatencyValues() []interface{} {
+	h := batchHist.Swap()
+	stalls := atomic.SwapUint64(&batchStalls, 0)
+	if h.Count() == 0 {
+		return []interface{}{0, nil, nil, nil, stalls}
+	}
+	return []interface{}{h.Count(),
+		float64(h.ValueAtQuantile(50)) / float64(time.Millisecond),
+		float64(h.ValueAtQuantile(99)) / float64(time.Millisecond),
+		float64(h.Max()) / float64(time.Millisecond),
+		stalls}
+}
+
 func readingWorker(index bleve.Index, work chan *Work) {
 	wikiReader, err := blevebench.NewWikiReader(*source)
 	if err != nil {
@@ -239,66 +556,116 @@ func readingWorker(index bleve.Index, work chan *Work) {
 	}
 	defer wikiReader.Close()
 
+	// i numbers the inserted docs, ops counts all operations
 	i := 0
+	ops := 0
 
-	if *batchSize > 1 {
+	if *batchSize > 1 || *batchBytes > 0 {
 		batch := index.NewBatch()
 		bytesInBatch := uint64(0)
+		var probeIDs, probeMarkers []string
+		var counts [3]int
 		a, err := wikiReader.Next()
-		for a != nil && err == nil && i < *count {
-			if *maxTextSize > 0 && len(a.Text) > *maxTextSize {
-				a.Text = a.Text[0:*maxTextSize]
-			}
+		for a != nil && err == nil && ops < *count && !stopReading() {
+			n := ops
+			ops++
+			o, id := mix.next()
+			counts[o]++
+			if o == opDelete {
+				batch.Delete(id)
+			} else {
+				if *maxTextSize > 0 && len(a.Text) > *maxTextSize {
+					a.Text = a.Text[0:*maxTextSize]
+				}
 
-			err = batch.Index(strconv.Itoa(i), a)
-			i++
-			if err != nil {
-				break
+				if o == opInsert {
+					id = strconv.Itoa(i)
+					i++
+					mix.inserted(id)
+				}
+				doc, marker := probeDoc(n, id, a)
+				if marker != "" {
+					probeIDs = append(probeIDs, id)
+					probeMarkers = append(probeMarkers, marker)
+				}
+				err = batch.Index(id, doc)
+				if err != nil {
+					break
+				}
+				bytesInBatch += uint64(len(a.Title))
+				bytesInBatch += uint64(len(a.Text))
 			}
-			bytesInBatch += uint64(len(a.Title))
-			bytesInBatch += uint64(len(a.Text))
-			if batch.Size() >= *batchSize {
-				work <- &Work{
+			if blevebench.BatchFull(batch.Size(), bytesInBatch, *batchSize, *batchBytes, *batchMaxDocs) {
+				throttle(batch.Size(), bytesInBatch)
+				sendWork(work, &Work{
 					batch:          batch,
 					plainTextBytes: bytesInBatch,
-				}
+					probeIDs:       probeIDs,
+					probeMarkers:   probeMarkers,
+					counts:         counts,
+				})
 				batch = index.NewBatch()
 				bytesInBatch = 0
+				probeIDs, probeMarkers = nil, nil
+				counts = [3]int{}
 			}
 
-			a, err = wikiReader.Next()
+			if o != opDelete {
+				a, err = wikiReader.Next()
+			}
 		}
 		if err != nil {
 			log.Fatalf("reading worker fatal: %v", err)
 		}
 		// close last batch
 		if batch.Size() > 0 {
-			work <- &Work{
+			throttle(batch.Size(), bytesInBatch)
+			sendWork(work, &Work{
 				batch:          batch,
 				plainTextBytes: bytesInBatch,
-			}
+				probeIDs:       probeIDs,
+				probeMarkers:   probeMarkers,
+				counts:         counts,
+			})
 		}
 
 	} else {
 		a, err := wikiReader.Next()
-		for a != nil && err == nil && i <= *count {
-			if *maxTextSize > 0 && len(a.Text) > *maxTextSize {
-				a.Text = a.Text[0:*maxTextSize]
-			}
+		for a != nil && err == nil && ops <= *count && !stopReading() {
+			ops++
+			o, id := mix.next()
+			w := &Work{id: id, delete: o == opDelete}
+			w.counts[o]++
+			if o != opDelete {
+				if *maxTextSize > 0 && len(a.Text) > *maxTextSize {
+					a.Text = a.Text[0:*maxTextSize]
+				}
 
-			i++
-			work <- &Work{
-				doc:            a,
-				id:             strconv.Itoa(i),
-				plainTextBytes: uint64(len(a.Title) + len(a.Text)),
+				if o == opInsert {
+					i++
+					w.id = strconv.Itoa(i)
+					mix.inserted(w.id)
+				}
+				w.plainTextBytes = uint64(len(a.Title) + len(a.Text))
+				var marker string
+				w.doc, marker = probeDoc(ops, w.id, a)
+				if marker != "" {
+					w.probeIDs = []string{w.id}
+					w.probeMarkers = []string{marker}
+				}
+			}
+			throttle(1, w.plainTextBytes)
+			sendWork(work, w)
+			if o != opDelete {
+				a, err = wikiReader.Next()
 			}
-			a, err = wikiReader.Next()
 		}
 		if err != nil {
 			log.Fatalf("reading worker fatal: %v", err)
 		}
 	}
 
+	atomic.StoreInt64(&readingDone, time.Now().UnixNano())
 	close(work)
 
 	// dump mem stats if requested
@@ -313,21 +680,44 @@ func readingWorker(index bleve.Index, work chan *Work) {
 }
 
 func batchIndexingWorker(index bleve.Index, workChan chan *Work, timeStart time.Time) {
-	for work := range workChan {
+	for {
+		work, ok := receiveWork(workChan)
+		if !ok {
+			return
+		}
 		workSize := 1
+		workStart := time.Now()
 		if work.batch != nil {
 			err := index.Batch(work.batch)
 			if err != nil {
 				log.Fatalf("indexer worker fatal: %v", err)
 			}
 			workSize = work.batch.Size()
+			batchDocsHist.Record(int64(workSize))
+			batchBytesHist.Record(int64(work.plainTextBytes))
+		} else if work.delete {
+			err := index.Delete(work.id)
+			if err != nil {
+				log.Fatalf("indexer worker fatal: %v", err)
+			}
 		} else {
 			err := index.Index(work.id, work.doc)
 			if err != nil {
 				log.Fatalf("indexer worker fatal: %v", err)
 			}
 		}
+		duration := time.Since(workStart)
+		batchHist.RecordDuration(duration)
+		if duration > *stallThreshold {
+			atomic.AddUint64(&batchStalls, 1)
+		}
+		if len(work.probeIDs) > 0 {
+			probes.indexed(work.probeIDs, work.probeMarkers)
+		}
 		atomic.AddUint64(&totalIndexed, uint64(workSize))
+		atomic.AddUint64(&totalInserted, uint64(work.counts[opInsert]))
+		atomic.AddUint64(&totalUpdated, uint64(work.counts[opUpdate]))
+		atomic.AddUint64(&totalDeleted, uint64(work.counts[opDelete]))
 		atomic.AddUint64(&totalPlainTextIndexed, work.plainTextBytes)
 	}
 }
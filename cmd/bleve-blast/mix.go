@@ -0,0 +1,144 @@
+//  Copyright (c) 2019 Couchbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/blevesearch/bleve-bench/stats"
+)
+
+type op int
+
+const (
+	opInsert op = iota
+	opUpdate
+	opDelete
+)
+
+var opNames = []string{"insert", "update", "delete"}
+
+var mixFields = []stats.Field{
+	stats.Int("inserts", "docs"),
+	stats.Int("updates", "docs"),
+	stats.Int("deletes", "docs"),
+}
+
+// workloadMix chooses the operation to perform next, at random with
+// the configured weights, keeping track of the live doc IDs which
+// may be updated or deleted.  It is only used by the reading worker.
+// A nil workloadMix always inserts.  IDs are live once their insert
+// has been sent to the indexers, not once it has been applied, so with
+// more than one indexer an update or delete can reach the index first.
+type workloadMix struct {
+	weights [3]int
+	total   int
+	rng     *rand.Rand
+	live    []string
+
+	// busy, when set, returns true for live IDs which must not be
+	// updated or deleted yet
+	busy func(id string) bool
+}
+
+// parseWorkloadMix returns the workloadMix for the -mix flag, nil when
+// it is empty
+func parseWorkloadMix() (*workloadMix, error) {
+	if *workload == "" {
+		return nil, nil
+	}
+	return parseMix(*workload, *mixSeed)
+}
+
+// parseMix parses a mix such as "insert:70,update:25,delete:5", the
+// weights are relative so need not add up to 100
+func parseMix(spec string, seed int64) (*workloadMix, error) {
+	m := &workloadMix{rng: rand.New(rand.NewSource(seed))}
+	var seen [3]bool
+	for _, part := range strings.Split(spec, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), ":", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid mix entry '%s', expected op:weight", part)
+		}
+		weight, err := strconv.Atoi(kv[1])
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("invalid weight in mix entry '%s'", part)
+		}
+		o := -1
+		for i, name := range opNames {
+			if kv[0] == name {
+				o = i
+			}
+		}
+		if o < 0 {
+			return nil, fmt.Errorf("unknown op '%s' in mix, must be one of %v", kv[0], opNames)
+		}
+		if seen[o] {
+			return nil, fmt.Errorf("op '%s' repeated in mix", kv[0])
+		}
+		seen[o] = true
+		m.weights[o] = weight
+		m.total += weight
+	}
+	if m.weights[opInsert] == 0 {
+		return nil, fmt.Errorf("mix must include inserts")
+	}
+	return m, nil
+}
+
+// next returns the next operation, along with the ID to update or
+// delete, inserting while there are no live IDs which are not busy
+func (m *workloadMix) next() (op, string) {
+	if m == nil || len(m.live) == 0 {
+		return opInsert, ""
+	}
+	r := m.rng.Intn(m.total)
+	if r < m.weights[opInsert] {
+		return opInsert, ""
+	}
+	i, ok := m.pick()
+	if !ok {
+		return opInsert, ""
+	}
+	id := m.live[i]
+	if r < m.weights[opInsert]+m.weights[opUpdate] {
+		return opUpdate, id
+	}
+	m.live[i] = m.live[len(m.live)-1]
+	m.live = m.live[:len(m.live)-1]
+	return opDelete, id
+}
+
+// pick returns the position of a random live ID which is not busy,
+// giving up after a few tries, so inserting instead, when most are
+func (m *workloadMix) pick() (int, bool) {
+	for try := 0; try < 3; try++ {
+		i := m.rng.Intn(len(m.live))
+		if m.busy == nil || !m.busy(m.live[i]) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// inserted records the ID of a new doc
+func (m *workloadMix) inserted(id string) {
+	if m != nil {
+		m.live = append(m.live, id)
+	}
+}
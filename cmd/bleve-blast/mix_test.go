@@ -0,0 +1,94 @@
+//  Copyright (c) 2019 Couchbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestParseMix(t *testing.T) {
+	m, err := parseMix("insert:70, update:25,delete:5", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.weights != [3]int{70, 25, 5} || m.total != 100 {
+		t.Errorf("expected weights [70 25 5] totalling 100, got %v totalling %d", m.weights, m.total)
+	}
+
+	for _, spec := range []string{
+		"insert:70,insert:30",
+		"insert:70,upsert:30",
+		"insert",
+		"insert:-1",
+		"update:25,delete:5",
+	} {
+		_, err = parseMix(spec, 1)
+		if err == nil {
+			t.Errorf("expected error parsing mix '%s'", spec)
+		}
+	}
+}
+
+func TestWorkloadMixNext(t *testing.T) {
+	m, err := parseMix("insert:70,update:25,delete:5", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// with no live IDs only inserts are done
+	for i := 0; i < 100; i++ {
+		o, id := m.next()
+		if o != opInsert || id != "" {
+			t.Fatalf("expected insert with no live IDs, got %s '%s'", opNames[o], id)
+		}
+	}
+
+	for i := 0; i < 1000; i++ {
+		m.inserted(strconv.Itoa(i))
+	}
+	var counts [3]int
+	deleted := map[string]bool{}
+	for i := 0; i < 10000; i++ {
+		o, id := m.next()
+		counts[o]++
+		switch o {
+		case opInsert:
+			m.inserted("new" + strconv.Itoa(i))
+		case opUpdate, opDelete:
+			if deleted[id] {
+				t.Fatalf("%s of deleted ID '%s'", opNames[o], id)
+			}
+			if o == opDelete {
+				deleted[id] = true
+			}
+		}
+	}
+	// the counts follow the weights, within a margin for randomness
+	for o, want := range []int{7000, 2500, 500} {
+		if counts[o] < want*8/10 || counts[o] > want*12/10 {
+			t.Errorf("expected about %d %ss, got %d", want, opNames[o], counts[o])
+		}
+	}
+}
+
+func TestWorkloadMixNil(t *testing.T) {
+	var m *workloadMix
+	m.inserted("1")
+	o, id := m.next()
+	if o != opInsert || id != "" {
+		t.Errorf("expected nil mix to insert, got %s '%s'", opNames[o], id)
+	}
+}
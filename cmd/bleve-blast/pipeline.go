@@ -0,0 +1,163 @@
+//  Copyright (c) 2019 Couchbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve-bench/stats"
+	"github.com/dustin/go-jsonpointer"
+)
+
+var pipelineFields = []stats.Field{
+	stats.Float("queue_depth_avg", "items"),
+	stats.Int("queue_depth_max", "items"),
+	stats.Float("reader_blocked_pct", "%"),
+	stats.Float("indexer_wait_pct", "%"),
+}
+
+// pipelineMonitor records how busy each stage of the pipeline is: the
+// depth of the work queue seen by the reading worker each time it
+// sends, the time it spends blocked on a full queue, and the time the
+// indexers spend waiting on an empty one
+type pipelineMonitor struct {
+	m             sync.Mutex
+	depthSum      uint64
+	depthSamples  uint64
+	depthMax      int
+	readerBlocked time.Duration
+	indexerWait   time.Duration
+
+	// totals over the run
+	runReaderBlocked time.Duration
+	runIndexerWait   time.Duration
+}
+
+var pipeline pipelineMonitor
+
+// sendWork sends w to the indexers, recording the queue depth and how
+// long the reader was blocked by a full queue
+func sendWork(work chan *Work, w *Work) {
+	depth := len(work)
+	start := time.Now()
+	work <- w
+	blocked := time.Since(start)
+
+	pipeline.m.Lock()
+	pipeline.depthSum += uint64(depth)
+	pipeline.depthSamples++
+	if depth > pipeline.depthMax {
+		pipeline.depthMax = depth
+	}
+	pipeline.readerBlocked += blocked
+	pipeline.runReaderBlocked += blocked
+	pipeline.m.Unlock()
+}
+
+// receiveWork receives the next work for an indexer, recording how
+// long it waited for it
+func receiveWork(work chan *Work) (*Work, bool) {
+	start := time.Now()
+	w, ok := <-work
+	wait := time.Since(start)
+
+	pipeline.m.Lock()
+	pipeline.indexerWait += wait
+	pipeline.runIndexerWait += wait
+	pipeline.m.Unlock()
+	return w, ok
+}
+
+// values returns the values of the pipelineFields for the interval
+// of the given length since the previous call, and resets them
+func (p *pipelineMonitor) values(interval time.Duration) []interface{} {
+	p.m.Lock()
+	defer p.m.Unlock()
+	var depthAvg interface{}
+	if p.depthSamples > 0 {
+		depthAvg = float64(p.depthSum) / float64(p.depthSamples)
+	}
+	rv := []interface{}{depthAvg, p.depthMax,
+		percentOf(p.readerBlocked, interval),
+		percentOf(p.indexerWait, interval*time.Duration(*numIndexers))}
+	p.depthSum = 0
+	p.depthSamples = 0
+	p.depthMax = 0
+	p.readerBlocked = 0
+	p.indexerWait = 0
+	return rv
+}
+
+func percentOf(d, total time.Duration) interface{} {
+	if total <= 0 {
+		return nil
+	}
+	// waits are recorded when they end, so one can span intervals
+	pct := 100 * float64(d) / float64(total)
+	if pct > 100 {
+		pct = 100
+	}
+	return pct
+}
+
+// busyThreshold is the percentage of time blocked, or waiting, beyond
+// which a stage is considered to be held up
+const busyThreshold = 50
+
+// verdict describes which stage limited the run of the given length.
+// When the indexers are the bottleneck, the analysis and index times
+// in the index StatsMap, when present, tell analysis and the store
+// apart.
+func (p *pipelineMonitor) verdict(index bleve.Index, elapsed time.Duration) string {
+	p.m.Lock()
+	readerBlocked := 100 * float64(p.runReaderBlocked) / float64(elapsed)
+	indexerWait := 100 * float64(p.runIndexerWait) / float64(elapsed*time.Duration(*numIndexers))
+	p.m.Unlock()
+
+	summary := fmt.Sprintf("reader blocked %.1f%% of the time, indexers waiting %.1f%%",
+		readerBlocked, indexerWait)
+	switch {
+	case indexerWait >= busyThreshold && readerBlocked < busyThreshold:
+		return "bottleneck: reader, " + summary
+	case readerBlocked >= busyThreshold && indexerWait < busyThreshold:
+		return fmt.Sprintf("bottleneck: %s, %s", indexingBottleneck(index), summary)
+	}
+	return "bottleneck: none clear, " + summary
+}
+
+// indexingBottleneck compares the time spent analyzing with the time
+// spent updating the index, as reported by upside_down or scorch
+func indexingBottleneck(index bleve.Index) string {
+	statsMap := index.StatsMap()
+	for _, keys := range [][2]string{
+		{"/index/analysis_time", "/index/index_time"},
+		{"/index/TotAnalysisTime", "/index/TotIndexTime"},
+	} {
+		analysis, ok1 := jsonpointer.Reflect(statsMap, keys[0]).(uint64)
+		indexing, ok2 := jsonpointer.Reflect(statsMap, keys[1]).(uint64)
+		if ok1 && ok2 && analysis+indexing > 0 {
+			if analysis > indexing {
+				return fmt.Sprintf("indexers (analysis, %.0f%% of indexing time)",
+					100*float64(analysis)/float64(analysis+indexing))
+			}
+			return fmt.Sprintf("indexers (store, %.0f%% of indexing time)",
+				100*float64(indexing)/float64(analysis+indexing))
+		}
+	}
+	return "indexers"
+}
@@ -0,0 +1,91 @@
+//  Copyright (c) 2019 Couchbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve-bench"
+)
+
+func TestPipelineVerdict(t *testing.T) {
+	index, err := bleve.NewMemOnly(blevebench.BuildArticleMapping())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer index.Close()
+	oldIndexers := *numIndexers
+	*numIndexers = 2
+	defer func() {
+		*numIndexers = oldIndexers
+	}()
+
+	elapsed := 10 * time.Second
+	for _, test := range []struct {
+		readerBlocked time.Duration
+		indexerWait   time.Duration // over both indexers
+		expected      string
+	}{
+		{8 * time.Second, 2 * time.Second, "bottleneck: indexers"},
+		{time.Second, 18 * time.Second, "bottleneck: reader"},
+		{time.Second, 2 * time.Second, "bottleneck: none clear"},
+		{8 * time.Second, 18 * time.Second, "bottleneck: none clear"},
+		// the threshold itself counts as held up
+		{5 * time.Second, 2 * time.Second, "bottleneck: indexers"},
+		{4 * time.Second, 10 * time.Second, "bottleneck: reader"},
+	} {
+		p := &pipelineMonitor{
+			runReaderBlocked: test.readerBlocked,
+			runIndexerWait:   test.indexerWait,
+		}
+		verdict := p.verdict(index, elapsed)
+		if !strings.HasPrefix(verdict, test.expected) {
+			t.Errorf("reader blocked %v, indexers waiting %v: expected '%s', got '%s'",
+				test.readerBlocked, test.indexerWait, test.expected, verdict)
+		}
+	}
+}
+
+func TestPipelineValues(t *testing.T) {
+	oldIndexers := *numIndexers
+	*numIndexers = 2
+	defer func() {
+		*numIndexers = oldIndexers
+	}()
+
+	p := &pipelineMonitor{
+		depthSum:      6,
+		depthSamples:  3,
+		depthMax:      4,
+		readerBlocked: 250 * time.Millisecond,
+		indexerWait:   3 * time.Second,
+	}
+	rv := p.values(time.Second)
+	// the indexer wait covers both indexers, a wait spanning
+	// intervals is capped at 100%
+	expected := []interface{}{2.0, 4, 25.0, 100.0}
+	for i := range expected {
+		if rv[i] != expected[i] {
+			t.Errorf("expected %s %v, got %v", pipelineFields[i].Name, expected[i], rv[i])
+		}
+	}
+	rv = p.values(time.Second)
+	if rv[0] != nil || rv[1] != 0 || rv[2] != 0.0 || rv[3] != 0.0 {
+		t.Errorf("expected values reset, got %v", rv)
+	}
+}
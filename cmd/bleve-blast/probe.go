@@ -0,0 +1,214 @@
+//  Copyright (c) 2019 Couchbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve-bench"
+	"github.com/blevesearch/bleve-bench/stats"
+	"github.com/blevesearch/bleve/search/query"
+)
+
+var probeFields = []stats.Field{
+	stats.Int("probes_visible", "docs"),
+	stats.Int("probe_timeouts", "docs"),
+	stats.Float("probe_lag_p50_ms", "ms"),
+	stats.Float("probe_lag_p90_ms", "ms"),
+	stats.Float("probe_lag_p99_ms", "ms"),
+	stats.Float("probe_lag_max_ms", "ms"),
+}
+
+// probeDoc returns the doc to index with the id for the nth article,
+// tagging every probeEvery'th one with a marker term when probing
+func probeDoc(n int, id string, a *blevebench.Article) (interface{}, string) {
+	if probes == nil || n%*probeEvery != 0 {
+		return a, ""
+	}
+	marker := "probe-" + strconv.Itoa(n)
+	probes.tagged(id)
+	return &probeArticle{Article: *a, Probe: marker}, marker
+}
+
+// probeArticle is an article tagged with a marker term, the embedded
+// article fields are indexed as if it were untagged
+type probeArticle struct {
+	blevebench.Article
+	Probe string `json:"probe"`
+}
+
+type probe struct {
+	id      string
+	marker  string
+	indexed time.Time
+}
+
+// prober polls the index for the marker terms of the probe docs,
+// recording the time between the indexing call returning and the
+// doc being found by a search
+type prober struct {
+	index    bleve.Index
+	interval time.Duration
+	timeout  time.Duration
+	probes   chan probe
+	done     chan struct{}
+
+	m           sync.Mutex
+	ids         map[string]struct{}
+	lag         *blevebench.Histogram
+	runLag      *blevebench.Histogram
+	timeouts    uint64
+	runTimeouts uint64
+}
+
+func newProber(index bleve.Index, interval, timeout time.Duration) *prober {
+	return &prober{
+		index:    index,
+		interval: interval,
+		timeout:  timeout,
+		probes:   make(chan probe, 1000),
+		done:     make(chan struct{}),
+		ids:      make(map[string]struct{}),
+		lag:      blevebench.NewHistogram(),
+		runLag:   blevebench.NewHistogram(),
+	}
+}
+
+// tagged is called when the doc with the id is tagged with a marker,
+// it is then waited on until found or timed out
+func (p *prober) tagged(id string) {
+	p.m.Lock()
+	p.ids[id] = struct{}{}
+	p.m.Unlock()
+}
+
+// waiting returns true while the probe doc with the id is waited on
+func (p *prober) waiting(id string) bool {
+	p.m.Lock()
+	defer p.m.Unlock()
+	_, ok := p.ids[id]
+	return ok
+}
+
+// indexed is called once the indexing call containing the probe docs
+// has returned
+func (p *prober) indexed(ids, markers []string) {
+	now := time.Now()
+	for i := range ids {
+		p.probes <- probe{id: ids[i], marker: markers[i], indexed: now}
+	}
+}
+
+// close stops accepting probes and waits until the pending probes have
+// been found or timed out
+func (p *prober) close() {
+	close(p.probes)
+	<-p.done
+}
+
+func (p *prober) run() {
+	defer close(p.done)
+	pending := make(map[string]probe)
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	probes := p.probes
+	for probes != nil || len(pending) > 0 {
+		select {
+		case pr, ok := <-probes:
+			if !ok {
+				probes = nil
+				continue
+			}
+			pending[pr.id] = pr
+		case <-ticker.C:
+			p.poll(pending)
+		}
+	}
+}
+
+// poll searches for all the pending markers at once, the hits are the
+// probes which have become visible
+func (p *prober) poll(pending map[string]probe) {
+	if len(pending) == 0 {
+		return
+	}
+	queries := make([]query.Query, 0, len(pending))
+	for _, pr := range pending {
+		tq := bleve.NewTermQuery(pr.marker)
+		tq.SetField("probe")
+		queries = append(queries, tq)
+	}
+	req := bleve.NewSearchRequestOptions(bleve.NewDisjunctionQuery(queries...), len(pending), 0, false)
+	res, err := p.index.Search(req)
+	if err != nil {
+		log.Fatalf("error searching for probes: %v", err)
+	}
+	now := time.Now()
+
+	p.m.Lock()
+	defer p.m.Unlock()
+	for _, hit := range res.Hits {
+		if pr, ok := pending[hit.ID]; ok {
+			lag := now.Sub(pr.indexed)
+			p.lag.RecordDuration(lag)
+			p.runLag.RecordDuration(lag)
+			delete(pending, hit.ID)
+			delete(p.ids, hit.ID)
+		}
+	}
+	for id, pr := range pending {
+		if now.Sub(pr.indexed) > p.timeout {
+			p.timeouts++
+			p.runTimeouts++
+			delete(pending, id)
+			delete(p.ids, id)
+		}
+	}
+}
+
+// values returns the values of the probeFields for the probes seen
+// since the previous call, and resets them
+func (p *prober) values() []interface{} {
+	p.m.Lock()
+	defer p.m.Unlock()
+	rv := []interface{}{p.lag.Count(), p.timeouts}
+	if p.lag.Count() > 0 {
+		rv = append(rv, ms(p.lag.ValueAtQuantile(50)), ms(p.lag.ValueAtQuantile(90)),
+			ms(p.lag.ValueAtQuantile(99)), ms(p.lag.Max()))
+	} else {
+		rv = append(rv, nil, nil, nil, nil)
+	}
+	p.lag.Reset()
+	p.timeouts = 0
+	return rv
+}
+
+// summary logs the distribution of the lag over the whole run
+func (p *prober) summary() {
+	p.m.Lock()
+	defer p.m.Unlock()
+	h := p.runLag
+	log.Printf("visibility lag over %d probes (%d timed out): p50 %.3fms, p90 %.3fms, p99 %.3fms, p99.9 %.3fms, max %.3fms",
+		h.Count(), p.runTimeouts, ms(h.ValueAtQuantile(50)), ms(h.ValueAtQuantile(90)),
+		ms(h.ValueAtQuantile(99)), ms(h.ValueAtQuantile(99.9)), ms(h.Max()))
+}
+
+func ms(v int64) float64 {
+	return float64(v) / float64(time.Millisecond)
+}
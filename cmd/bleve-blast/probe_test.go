@@ -0,0 +1,143 @@
+//  Copyright (c) 2019 Couchbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve-bench"
+)
+
+// newTestProber returns a running prober, installed as probes, over
+// an in memory index tagging every probeEvery'th doc, along with a
+// func restoring the previous state
+func newTestProber(t *testing.T, every int) (bleve.Index, *prober, func()) {
+	index, err := bleve.NewMemOnly(blevebench.BuildArticleMapping())
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldEvery := *probeEvery
+	*probeEvery = every
+	probes = newProber(index, time.Millisecond, 200*time.Millisecond)
+	go probes.run()
+	return index, probes, func() {
+		*probeEvery = oldEvery
+		probes = nil
+		index.Close()
+	}
+}
+
+func TestProbeDoc(t *testing.T) {
+	a := &blevebench.Article{Title: "title", Text: "text"}
+	doc, marker := probeDoc(3, "x", a)
+	if doc != a || marker != "" {
+		t.Errorf("expected untagged article when not probing, got %v '%s'", doc, marker)
+	}
+
+	_, _, cleanup := newTestProber(t, 2)
+	defer cleanup()
+	doc, marker = probeDoc(3, "x", a)
+	if doc != a || marker != "" {
+		t.Errorf("expected untagged article for odd doc, got %v '%s'", doc, marker)
+	}
+	doc, marker = probeDoc(4, "x", a)
+	pa, ok := doc.(*probeArticle)
+	if !ok || marker != "probe-4" || pa.Probe != marker || pa.Title != a.Title {
+		t.Errorf("expected article tagged probe-4, got %v '%s'", doc, marker)
+	}
+}
+
+func TestProber(t *testing.T) {
+	index, p, cleanup := newTestProber(t, 1)
+	defer cleanup()
+
+	doc, marker := probeDoc(1, "1", &blevebench.Article{Title: "visible", Text: "text"})
+	err := index.Index("1", doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the second probe doc is never indexed, so can only time out
+	p.indexed([]string{"1", "2"}, []string{marker, "probe-2"})
+
+	start := time.Now()
+	p.close()
+	if took := time.Since(start); took > 5*time.Second {
+		t.Errorf("expected close to return once the probe timed out, took %v", took)
+	}
+	rv := p.values()
+	if rv[0] != uint64(1) || rv[1] != uint64(1) {
+		t.Errorf("expected 1 probe visible and 1 timeout, got %v", rv[:2])
+	}
+	for i, v := range rv[2:] {
+		if v == nil {
+			t.Errorf("expected %s for the visible probe", probeFields[i+2].Name)
+		}
+	}
+
+	// the values cover only the probes since the previous call
+	rv = p.values()
+	if rv[0] != uint64(0) || rv[1] != uint64(0) || rv[2] != nil {
+		t.Errorf("expected no probes after reset, got %v", rv)
+	}
+}
+
+func TestProberWithMix(t *testing.T) {
+	index, p, cleanup := newTestProber(t, 2)
+	defer cleanup()
+	mix, err := parseMix("insert:50,update:25,delete:25", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mix.busy = p.waiting
+
+	// index as the reading worker would, overwriting or deleting a
+	// probe doc before it is found would remove its marker
+	inserts := 0
+	for n := 1; n <= 1000; n++ {
+		o, id := mix.next()
+		if o != opInsert && p.waiting(id) {
+			t.Fatalf("%s of probe doc '%s' which has not been found", opNames[o], id)
+		}
+		if o == opDelete {
+			err = index.Delete(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if o == opInsert {
+			inserts++
+			id = strconv.Itoa(inserts)
+			mix.inserted(id)
+		}
+		doc, marker := probeDoc(n, id, &blevebench.Article{Title: id, Text: "text"})
+		err = index.Index(id, doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if marker != "" {
+			p.indexed([]string{id}, []string{marker})
+		}
+	}
+	p.close()
+
+	rv := p.values()
+	if rv[0] == uint64(0) || rv[1] != uint64(0) {
+		t.Errorf("expected all probes visible without timeouts, got %v", rv[:2])
+	}
+}
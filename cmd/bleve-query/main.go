@@ -17,7 +17,6 @@ package main
 import (
 	_ "expvar"
 	"flag"
-	"fmt"
 	"log"
 	"math/rand"
 	"net/http"
@@ -25,12 +24,14 @@ import (
 	"path"
 	"runtime/pprof"
 	"runtime/trace"
-	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve-bench"
+	"github.com/blevesearch/bleve-bench/dashboard"
+	"github.com/blevesearch/bleve-bench/stats"
 	_ "github.com/blevesearch/bleve/config"
 	"github.com/blevesearch/bleve/search/query"
 )
@@ -38,6 +39,7 @@ import (
 var target = flag.String("index", "bench.bleve", "index filename")
 var bindHTTP = flag.String("bindHttp", ":1234", "http bind port")
 var statsFile = flag.String("statsFile", "", "<stdout>")
+var statsFormat = flag.String("statsFormat", "csv", "format of the stats output: csv, json")
 var cpuprofile = flag.String("cpuprofile", "", "write cpu profile to file")
 var qtype = flag.String("queryType", "term", "type of query to execute: term, prefix, query_string")
 var qfield = flag.String("field", "text", "the field to query, not applicable to query_string queries")
@@ -45,8 +47,15 @@ var qclients = flag.Int("clients", 1, "the number of query clients")
 var qtime = flag.Duration("time", 1*time.Minute, "time to run the test")
 var printTime = flag.Duration("printTime", 5*time.Second, "print stats every printTime")
 var traceprofile = flag.String("traceprofile", "", "write trace profile to file")
+var indexStats = flag.String("indexStats", "", "index StatsMap values to report every printTime: all, or a comma separated list of JSON pointers")
+var memStats = flag.Bool("memStats", false, "report go runtime memory and GC stats every printTime")
 
 var statsWriter = os.Stdout
+var statsEmitter stats.Emitter
+var memSampler = blevebench.NewMemSampler()
+var index bleve.Index
+var publishedIndex *blevebench.PublishedIndex
+var idxStats *blevebench.IndexStats
 
 var queriesStarted uint64
 var queriesFinished uint64
@@ -57,7 +66,15 @@ var timeLast time.Time
 func main() {
 	flag.Parse()
 
-	go http.ListenAndServe(*bindHTTP, nil) // For expvar.
+	// deferred first, so the profiles are stopped and flushed before
+	// exiting with the signal status
+	interrupt := blevebench.HandleInterrupts()
+	defer interrupt.ExitIfInterrupted()
+
+	dash := dashboard.New("bleve-query")
+	http.Handle("/", dash)
+	go http.ListenAndServe(*bindHTTP, nil) // For expvar and the dashboard.
+	blevebench.SetPhase("starting")
 
 	if *statsFile != "" {
 		// create all parents if necessary
@@ -71,6 +88,33 @@ func main() {
 		}
 	}
 
+	var err error
+	index, err = bleve.Open(*target)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fields := outputFields
+	if *memStats {
+		fields = append(fields, blevebench.MemStatsFields...)
+	}
+	if *indexStats != "" {
+		idxStats, err = blevebench.NewIndexStats(index, *indexStats)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fields = append(fields, idxStats.Fields()...)
+	}
+	statsEmitter, err = stats.NewEmitter(statsWriter, *statsFormat, fields)
+	if err != nil {
+		log.Fatal(err)
+	}
+	statsEmitter, err = dash.Wrap(statsEmitter)
+	if err != nil {
+		log.Fatal(err)
+	}
+	publishVars()
+
 	if *cpuprofile != "" {
 		f, err := os.Create(*cpuprofile)
 		if err != nil {
@@ -120,15 +164,14 @@ func main() {
 		queries[i] = query
 	}
 
-	index, err := bleve.Open(*target)
-	if err != nil {
-		log.Fatal(err)
-	}
-
 	closeChan := make(chan struct{})
-	time.AfterFunc(*qtime, func() {
+	go func() {
+		select {
+		case <-time.After(*qtime):
+		case <-interrupt.Done():
+		}
 		close(closeChan)
-	})
+	}()
 
 	var wg sync.WaitGroup
 	for i := 0; i < *qclients; i++ {
@@ -139,6 +182,7 @@ func main() {
 		}()
 	}
 
+	blevebench.SetPhase("querying")
 	printHeader()
 	timeStart = time.Now()
 	timeLast = timeStart
@@ -154,7 +198,18 @@ func main() {
 	// print final stats
 	printLine()
 
-	index.Close()
+	publishedIndex.Close()
+	blevebench.SetPhase("done")
+}
+
+// publishVars publishes the counters, the last stats row, the config
+// and the index stats through expvar
+func publishVars() {
+	statsEmitter = blevebench.PublishRows("stats", statsEmitter)
+	blevebench.PublishConfig()
+	blevebench.PublishCounter("queries_started", &queriesStarted)
+	blevebench.PublishCounter("queries_finished", &queriesFinished)
+	publishedIndex = blevebench.PublishIndexStats(index)
 }
 
 func queryClient(index bleve.Index, queries []query.Query, closeChan chan struct{}) {
@@ -182,15 +237,18 @@ func queryClient(index bleve.Index, queries []query.Query, closeChan chan struct
 	}
 }
 
-var outputFields = []string{
-	"date",
-	"queries_finished",
-	"avg_queries_per_second",
-	"queries_per_second",
+var outputFields = []stats.Field{
+	stats.Time("date"),
+	stats.Int("queries_finished", "queries"),
+	stats.Float("avg_queries_per_second", "queries/s"),
+	stats.Float("queries_per_second", "queries/s"),
 }
 
 func printHeader() {
-	fmt.Fprintf(statsWriter, "%s\n", strings.Join(outputFields, ","))
+	err := statsEmitter.WriteHeader()
+	if err != nil {
+		log.Fatalf("error writing stats header: %v", err)
+	}
 }
 
 func printLine() {
@@ -207,9 +265,18 @@ func printLine() {
 	cumSeconds := float64(cumTimeTaken) / float64(time.Second)
 	curSeconds := float64(curTimeTaken) / float64(time.Second)
 
-	dateNow := timeNow.Format(time.RFC3339)
-	fmt.Fprintf(statsWriter, "%s,%d,%f,%f\n", dateNow, nowQueriesFinished,
-		float64(nowQueriesFinished)/cumSeconds, float64(curQueriesFinished)/curSeconds)
+	row := []interface{}{timeNow, nowQueriesFinished,
+		float64(nowQueriesFinished) / cumSeconds, float64(curQueriesFinished) / curSeconds}
+	if *memStats {
+		row = append(row, memSampler.Sample()...)
+	}
+	if idxStats != nil {
+		row = append(row, idxStats.Values(index)...)
+	}
+	err := statsEmitter.WriteRow(row)
+	if err != nil {
+		log.Fatalf("error writing stats: %v", err)
+	}
 
 	timeLast = timeNow
 	lastQueriesFinished = nowQueriesFinished
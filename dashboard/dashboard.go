@@ -0,0 +1,180 @@
+//  Copyright (c) 2019 Couchbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dashboard serves a page charting the rows of statistics
+// emitted by a benchmark command while it runs.
+package dashboard
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/blevesearch/bleve-bench/chart"
+	"github.com/blevesearch/bleve-bench/stats"
+)
+
+//go:embed dashboard.html
+var pageTemplate string
+
+var page = template.Must(template.New("dashboard").Parse(pageTemplate))
+
+type pageData struct {
+	Title       string
+	ChartScript template.JS
+	ChartStyle  template.CSS
+}
+
+// clientBuffer is the number of records a client may fall behind by
+// before it is disconnected
+const clientBuffer = 64
+
+// Dashboard is an http.Handler serving a page at / which charts every
+// numeric field of the stats rows as they are written.  The records
+// are streamed to the page as server-sent events from /events, in the
+// same form as the JSON lines stats output, header first.  Every
+// record is kept, so a page opened part way through a run shows the
+// whole of it.
+type Dashboard struct {
+	title string
+
+	m       sync.Mutex
+	records [][]byte
+	clients map[chan []byte]struct{}
+}
+
+// New returns a Dashboard with the given page title
+func New(title string) *Dashboard {
+	return &Dashboard{
+		title:   title,
+		clients: make(map[chan []byte]struct{}),
+	}
+}
+
+// Wrap returns an Emitter writing to e, which also sends everything
+// written to the dashboard
+func (d *Dashboard) Wrap(e stats.Emitter) (stats.Emitter, error) {
+	je, err := stats.NewEmitter(d, "json", e.Fields())
+	if err != nil {
+		return nil, err
+	}
+	return &teeEmitter{Emitter: e, dashboard: je}, nil
+}
+
+// Write receives one JSON lines record, and sends it to the clients
+func (d *Dashboard) Write(p []byte) (int, error) {
+	record := bytes.TrimSpace(append([]byte(nil), p...))
+	d.m.Lock()
+	defer d.m.Unlock()
+	d.records = append(d.records, record)
+	for c := range d.clients {
+		select {
+		case c <- record:
+		default:
+			// too slow, the page will reconnect and catch up
+			delete(d.clients, c)
+			close(c)
+		}
+	}
+	return len(p), nil
+}
+
+func (d *Dashboard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		err := page.Execute(w, pageData{
+			Title:       d.title,
+			ChartScript: chart.Script,
+			ChartStyle:  chart.Style,
+		})
+		if err != nil {
+			log.Printf("error serving dashboard: %v", err)
+		}
+	case "/events":
+		d.serveEvents(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (d *Dashboard) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	c := make(chan []byte, clientBuffer)
+	d.m.Lock()
+	records := d.records
+	d.clients[c] = struct{}{}
+	d.m.Unlock()
+	defer d.remove(c)
+
+	for _, record := range records {
+		fmt.Fprintf(w, "data: %s\n\n", record)
+	}
+	flusher.Flush()
+
+	for {
+		select {
+		case record, ok := <-c:
+			if !ok {
+				return
+			}
+			fmt.Fprintf(w, "data: %s\n\n", record)
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (d *Dashboard) remove(c chan []byte) {
+	d.m.Lock()
+	defer d.m.Unlock()
+	if _, ok := d.clients[c]; ok {
+		delete(d.clients, c)
+		close(c)
+	}
+}
+
+// teeEmitter writes to the stats output and the dashboard
+type teeEmitter struct {
+	stats.Emitter
+	dashboard stats.Emitter
+}
+
+func (t *teeEmitter) WriteHeader() error {
+	err := t.Emitter.WriteHeader()
+	if err != nil {
+		return err
+	}
+	return t.dashboard.WriteHeader()
+}
+
+func (t *teeEmitter) WriteRow(values []interface{}) error {
+	err := t.Emitter.WriteRow(values)
+	if err != nil {
+		return err
+	}
+	return t.dashboard.WriteRow(values)
+}
@@ -0,0 +1,85 @@
+//  Copyright (c) 2019 Couchbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dashboard
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/blevesearch/bleve-bench/stats"
+)
+
+func TestDashboardEvents(t *testing.T) {
+	d := New("test")
+	var out bytes.Buffer
+	csv, err := stats.NewEmitter(&out, "csv", []stats.Field{stats.Int("docs", "docs")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := d.Wrap(csv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = e.WriteHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = e.WriteRow([]interface{}{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "docs\n1\n" {
+		t.Errorf("expected the csv output unchanged, got %q", out.String())
+	}
+
+	srv := httptest.NewServer(d)
+	defer srv.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL+"/events", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	// the records so far are sent first, then the new ones
+	err = e.WriteRow([]interface{}{2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		`data: {"schema_version":1,"fields":[{"name":"docs","type":"int","unit":"docs"}]}`,
+		`data: {"docs":1}`,
+		`data: {"docs":2}`,
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	var events []string
+	for len(events) < len(expected) && scanner.Scan() {
+		if line := scanner.Text(); strings.HasPrefix(line, "data: ") {
+			events = append(events, line)
+		}
+	}
+	for i := range expected {
+		if i >= len(events) || events[i] != expected[i] {
+			t.Fatalf("expected events %v, got %v", expected, events)
+		}
+	}
+}
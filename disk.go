@@ -0,0 +1,55 @@
+package blevebench
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/blevesearch/bleve-bench/stats"
+)
+
+// DiskUsage walks the directory at path, returning the total size in
+// bytes of the regular files beneath it, along with the number of
+// files.  Files removed by the store while the walk is in progress
+// are skipped.
+func DiskUsage(path string) (uint64, uint64, error) {
+	var bytes, files uint64
+	err := filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && p != path {
+				return nil
+			}
+			return err
+		}
+		if info.Mode().IsRegular() {
+			bytes += uint64(info.Size())
+			files++
+		}
+		return nil
+	})
+	return bytes, files, err
+}
+
+// IndexSizeFields are the fields of the values returned by
+// IndexSizeValues
+var IndexSizeFields = []stats.Field{
+	stats.Int("index_bytes", "bytes"),
+	stats.Int("index_files", "files"),
+	stats.Float("index_bytes_per_doc", "bytes/doc"),
+	stats.Float("index_bytes_per_plaintext_mb", "bytes/MB"),
+}
+
+// IndexSizeValues walks the index at path, returning the values of
+// the IndexSizeFields for an index of docs docs and plainTextBytes
+// bytes of plaintext
+func IndexSizeValues(path string, docs, plainTextBytes uint64) ([]interface{}, error) {
+	bytes, files, err := DiskUsage(path)
+	if err != nil {
+		return nil, err
+	}
+	if docs == 0 || plainTextBytes == 0 {
+		return []interface{}{bytes, files, nil, nil}, nil
+	}
+	return []interface{}{bytes, files,
+		float64(bytes) / float64(docs),
+		float64(bytes) / (float64(plainTextBytes) / 1000000.0)}, nil
+}
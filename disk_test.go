@@ -0,0 +1,42 @@
+package blevebench
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiskUsage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blevebench")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "store"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "index_meta.json"), make([]byte, 10), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "store", "data"), make([]byte, 1000), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bytes, files, err := DiskUsage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes != 1010 || files != 2 {
+		t.Errorf("expected 1010 bytes in 2 files, got %d bytes in %d files", bytes, files)
+	}
+
+	_, _, err = DiskUsage(filepath.Join(dir, "missing"))
+	if err == nil {
+		t.Errorf("expected error for missing path")
+	}
+}
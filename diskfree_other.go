@@ -0,0 +1,15 @@
+//go:build !darwin && !freebsd && !linux
+// +build !darwin,!freebsd,!linux
+
+package blevebench
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// DiskFree returns the number of bytes available to unprivileged
+// users on the filesystem containing path
+func DiskFree(path string) (uint64, error) {
+	return 0, fmt.Errorf("disk free space not supported on %s", runtime.GOOS)
+}
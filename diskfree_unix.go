@@ -0,0 +1,19 @@
+//go:build darwin || freebsd || linux
+// +build darwin freebsd linux
+
+package blevebench
+
+import (
+	"syscall"
+)
+
+// DiskFree returns the number of bytes available to unprivileged
+// users on the filesystem containing path
+func DiskFree(path string) (uint64, error) {
+	var st syscall.Statfs_t
+	err := syscall.Statfs(path, &st)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), nil
+}
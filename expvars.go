@@ -0,0 +1,129 @@
+package blevebench
+
+import (
+	"expvar"
+	"flag"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve-bench/stats"
+)
+
+// PublishConfig publishes the value of every flag as the expvar
+// "config"
+func PublishConfig() {
+	expvar.Publish("config", expvar.Func(func() interface{} {
+		rv := make(map[string]string)
+		flag.VisitAll(func(f *flag.Flag) {
+			rv[f.Name] = f.Value.String()
+		})
+		return rv
+	}))
+}
+
+var phase *expvar.String
+var phaseOnce sync.Once
+
+// SetPhase sets the expvar "phase", describing what the benchmark is
+// currently doing
+func SetPhase(name string) {
+	phaseOnce.Do(func() {
+		phase = expvar.NewString("phase")
+	})
+	phase.Set(name)
+}
+
+// PublishCounter publishes the counter at p, which is updated
+// atomically, as the named expvar
+func PublishCounter(name string, p *uint64) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return atomic.LoadUint64(p)
+	}))
+}
+
+// PublishedIndex guards the index whose StatsMap is published as the
+// expvar "index_stats", so that it is not read while being closed or
+// replaced by another goroutine
+type PublishedIndex struct {
+	m     sync.RWMutex
+	index bleve.Index
+}
+
+// PublishIndexStats publishes the StatsMap of the index as the expvar
+// "index_stats", the index must then be closed or reopened through the
+// PublishedIndex returned
+func PublishIndexStats(index bleve.Index) *PublishedIndex {
+	rv := &PublishedIndex{index: index}
+	expvar.Publish("index_stats", expvar.Func(func() interface{} {
+		rv.m.RLock()
+		defer rv.m.RUnlock()
+		if rv.index == nil {
+			return nil
+		}
+		return rv.index.StatsMap()
+	}))
+	return rv
+}
+
+// Reopen reopens the index as Reopen does, publishing the stats of the
+// reopened index
+func (p *PublishedIndex) Reopen(path string, runtimeConfig map[string]interface{}) (bleve.Index, time.Duration, error) {
+	p.m.Lock()
+	defer p.m.Unlock()
+	index, took, err := Reopen(p.index, path, runtimeConfig)
+	p.index = index
+	return index, took, err
+}
+
+// Close closes the index, after which the stats published are null
+func (p *PublishedIndex) Close() error {
+	p.m.Lock()
+	defer p.m.Unlock()
+	err := p.index.Close()
+	p.index = nil
+	return err
+}
+
+// publishedEmitter is an Emitter which keeps the last row written,
+// keyed by field name, for publishing
+type publishedEmitter struct {
+	stats.Emitter
+	m    sync.Mutex
+	last map[string]interface{}
+}
+
+// PublishRows returns an Emitter writing to e, which also publishes
+// the last row written as the named expvar, an object keyed by field
+// name, so the latest rates can be read while the benchmark runs
+func PublishRows(name string, e stats.Emitter) stats.Emitter {
+	rv := &publishedEmitter{Emitter: e}
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		rv.m.Lock()
+		defer rv.m.Unlock()
+		return rv.last
+	}))
+	return rv
+}
+
+func (p *publishedEmitter) WriteRow(values []interface{}) error {
+	err := p.Emitter.WriteRow(values)
+	if err != nil {
+		return err
+	}
+	last := make(map[string]interface{}, len(values))
+	for i, f := range p.Fields() {
+		v := values[i]
+		// NaN and Inf can not be encoded as JSON
+		if fv, ok := v.(float64); ok && (math.IsNaN(fv) || math.IsInf(fv, 0)) {
+			v = nil
+		}
+		last[f.Name] = v
+	}
+	p.m.Lock()
+	p.last = last
+	p.m.Unlock()
+	return nil
+}
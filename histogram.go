@@ -0,0 +1,207 @@
+package blevebench
+
+import (
+	"math"
+	"math/bits"
+	"sync"
+	"time"
+)
+
+// histogramSubBucketBits controls the precision of the histogram,
+// values are tracked with a relative error of at most
+// 1/2^(histogramSubBucketBits-1), just under 1%
+const histogramSubBucketBits = 8
+
+const histogramSubBucketHalf = 1 << (histogramSubBucketBits - 1)
+
+// Histogram is an HDR-style log-linear histogram of non-negative
+// values.  Small values are counted exactly, larger values are
+// counted in buckets whose width grows with the magnitude of the
+// value, so the relative error stays bounded over the full range.
+// Histogram is not safe for concurrent use.
+type Histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+	min    int64
+	max    int64
+}
+
+// NewHistogram returns an empty histogram
+func NewHistogram() *Histogram {
+	return &Histogram{}
+}
+
+func histogramIndex(v int64) int {
+	if v < 2*histogramSubBucketHalf {
+		return int(v)
+	}
+	shift := bits.Len64(uint64(v)) - histogramSubBucketBits
+	top := int(v >> uint(shift))
+	return (shift+1)*histogramSubBucketHalf + top - histogramSubBucketHalf
+}
+
+// histogramValue returns the value in the middle of the bucket
+func histogramValue(idx int) int64 {
+	if idx < 2*histogramSubBucketHalf {
+		return int64(idx)
+	}
+	shift := uint(idx/histogramSubBucketHalf - 1)
+	top := int64(idx%histogramSubBucketHalf + histogramSubBucketHalf)
+	return top<<shift + (int64(1)<<shift)/2
+}
+
+// Record adds the value v to the histogram, negative values are
+// recorded as 0
+func (h *Histogram) Record(v int64) {
+	if v < 0 {
+		v = 0
+	}
+	idx := histogramIndex(v)
+	if idx >= len(h.counts) {
+		counts := make([]uint64, idx+1)
+		copy(counts, h.counts)
+		h.counts = counts
+	}
+	h.counts[idx]++
+	if h.count == 0 || v < h.min {
+		h.min = v
+	}
+	if v > h.max {
+		h.max = v
+	}
+	h.count++
+	h.sum += float64(v)
+}
+
+// RecordDuration adds the duration d to the histogram
+func (h *Histogram) RecordDuration(d time.Duration) {
+	h.Record(int64(d))
+}
+
+// Count returns the number of values recorded
+func (h *Histogram) Count() uint64 {
+	return h.count
+}
+
+// Min returns the smallest value recorded
+func (h *Histogram) Min() int64 {
+	return h.min
+}
+
+// Max returns the largest value recorded
+func (h *Histogram) Max() int64 {
+	return h.max
+}
+
+// Mean returns the average of the values recorded
+func (h *Histogram) Mean() float64 {
+	if h.count == 0 {
+		return math.NaN()
+	}
+	return h.sum / float64(h.count)
+}
+
+// ValueAtQuantile returns the value below which the given
+// percentage (0-100) of the recorded values fall
+func (h *Histogram) ValueAtQuantile(q float64) int64 {
+	if h.count == 0 {
+		return 0
+	}
+	if q >= 100 {
+		return h.max
+	}
+	if q < 0 {
+		q = 0
+	}
+	target := uint64(math.Ceil(q / 100 * float64(h.count)))
+	if target == 0 {
+		target = 1
+	}
+	var seen uint64
+	for idx, c := range h.counts {
+		seen += c
+		if seen >= target {
+			v := histogramValue(idx)
+			// never report beyond the observed range
+			if v > h.max {
+				v = h.max
+			}
+			if v < h.min {
+				v = h.min
+			}
+			return v
+		}
+	}
+	return h.max
+}
+
+// Merge adds all the values recorded in other to this histogram
+func (h *Histogram) Merge(other *Histogram) {
+	if other.count == 0 {
+		return
+	}
+	if len(other.counts) > len(h.counts) {
+		counts := make([]uint64, len(other.counts))
+		copy(counts, h.counts)
+		h.counts = counts
+	}
+	for idx, c := range other.counts {
+		h.counts[idx] += c
+	}
+	if h.count == 0 || other.min < h.min {
+		h.min = other.min
+	}
+	if other.max > h.max {
+		h.max = other.max
+	}
+	h.count += other.count
+	h.sum += other.sum
+}
+
+// Reset removes all values from the histogram
+func (h *Histogram) Reset() {
+	for i := range h.counts {
+		h.counts[i] = 0
+	}
+	h.count = 0
+	h.sum = 0
+	h.min = 0
+	h.max = 0
+}
+
+// ConcurrentHistogram is a Histogram which may be recorded into from
+// multiple goroutines, and read by swapping it for an empty one
+type ConcurrentHistogram struct {
+	m sync.Mutex
+	h *Histogram
+}
+
+// NewConcurrentHistogram returns an empty concurrent histogram
+func NewConcurrentHistogram() *ConcurrentHistogram {
+	return &ConcurrentHistogram{h: NewHistogram()}
+}
+
+// Record adds the value v to the histogram
+func (c *ConcurrentHistogram) Record(v int64) {
+	c.m.Lock()
+	c.h.Record(v)
+	c.m.Unlock()
+}
+
+// RecordDuration adds the duration d to the histogram
+func (c *ConcurrentHistogram) RecordDuration(d time.Duration) {
+	c.m.Lock()
+	c.h.RecordDuration(d)
+	c.m.Unlock()
+}
+
+// Swap returns the values recorded since the previous call, as a
+// Histogram owned by the caller, and starts recording afresh
+func (c *ConcurrentHistogram) Swap() *Histogram {
+	c.m.Lock()
+	defer c.m.Unlock()
+	rv := c.h
+	c.h = NewHistogram()
+	return rv
+}
@@ -0,0 +1,92 @@
+package blevebench
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHistogramQuantiles(t *testing.T) {
+	h := NewHistogram()
+	for i := int64(1); i <= 10000; i++ {
+		h.Record(i * 1000)
+	}
+
+	if h.Count() != 10000 {
+		t.Errorf("expected count 10000, got %d", h.Count())
+	}
+	if h.Min() != 1000 {
+		t.Errorf("expected min 1000, got %d", h.Min())
+	}
+	if h.Max() != 10000000 {
+		t.Errorf("expected max 10000000, got %d", h.Max())
+	}
+
+	tests := []struct {
+		quantile float64
+		expected float64
+	}{
+		{50, 5000000},
+		{90, 9000000},
+		{99, 9900000},
+		{99.9, 9990000},
+		{100, 10000000},
+	}
+	for _, test := range tests {
+		actual := float64(h.ValueAtQuantile(test.quantile))
+		if math.Abs(actual-test.expected)/test.expected > 0.01 {
+			t.Errorf("expected p%v within 1%% of %f, got %f", test.quantile, test.expected, actual)
+		}
+	}
+}
+
+func TestHistogramSmallValuesExact(t *testing.T) {
+	h := NewHistogram()
+	for i := int64(0); i < 100; i++ {
+		h.Record(i)
+	}
+	if h.ValueAtQuantile(50) != 49 {
+		t.Errorf("expected p50 49, got %d", h.ValueAtQuantile(50))
+	}
+	if h.Mean() != 49.5 {
+		t.Errorf("expected mean 49.5, got %f", h.Mean())
+	}
+}
+
+func TestHistogramMergeReset(t *testing.T) {
+	a := NewHistogram()
+	b := NewHistogram()
+	a.Record(10)
+	b.Record(1 << 40)
+	a.Merge(b)
+	if a.Count() != 2 || a.Min() != 10 || a.Max() != 1<<40 {
+		t.Errorf("unexpected merge result count %d min %d max %d", a.Count(), a.Min(), a.Max())
+	}
+	a.Reset()
+	if a.Count() != 0 || a.ValueAtQuantile(99) != 0 {
+		t.Errorf("expected empty histogram after reset")
+	}
+}
+
+func TestConcurrentHistogramSwap(t *testing.T) {
+	c := NewConcurrentHistogram()
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			for i := 0; i < 1000; i++ {
+				c.RecordDuration(time.Millisecond)
+			}
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+	h := c.Swap()
+	if h.Count() != 4000 {
+		t.Errorf("expected count 4000, got %d", h.Count())
+	}
+	if c.Swap().Count() != 0 {
+		t.Errorf("expected empty histogram after swap")
+	}
+}
@@ -0,0 +1,89 @@
+package blevebench
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve-bench/stats"
+	"github.com/dustin/go-jsonpointer"
+)
+
+// IndexStats samples numeric values from an index StatsMap, each
+// identified by its JSON pointer, such as /index/updates
+type IndexStats struct {
+	keys   []string
+	fields []stats.Field
+}
+
+// NewIndexStats returns an IndexStats for the keys in spec, which is
+// either "all", for every numeric value currently in the StatsMap of
+// the index, or a comma separated list of JSON pointers.  The columns
+// are fixed here, so values which later appear in the StatsMap are not
+// included, and those which are absent are emitted as missing.
+func NewIndexStats(index bleve.Index, spec string) (*IndexStats, error) {
+	statsMap := index.StatsMap()
+	rv := &IndexStats{}
+	if spec == "all" {
+		keys, err := jsonpointer.ReflectListPointers(statsMap)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			field, ok := indexStatField(key, jsonpointer.Reflect(statsMap, key))
+			if ok {
+				rv.keys = append(rv.keys, key)
+				rv.fields = append(rv.fields, field)
+			}
+		}
+		return rv, nil
+	}
+	for _, key := range strings.Split(spec, ",") {
+		key = strings.TrimSpace(key)
+		if !strings.HasPrefix(key, "/") {
+			return nil, fmt.Errorf("index stat '%s' is not a JSON pointer", key)
+		}
+		field, ok := indexStatField(key, jsonpointer.Reflect(statsMap, key))
+		if !ok {
+			// not present yet, or not numeric
+			field = stats.Float(indexStatName(key), "")
+		}
+		rv.keys = append(rv.keys, key)
+		rv.fields = append(rv.fields, field)
+	}
+	return rv, nil
+}
+
+func indexStatName(key string) string {
+	return "stat" + key
+}
+
+// indexStatField returns the field for a numeric value
+func indexStatField(key string, v interface{}) (stats.Field, bool) {
+	switch v.(type) {
+	case int, int32, int64, uint, uint32, uint64:
+		return stats.Int(indexStatName(key), ""), true
+	case float32, float64:
+		return stats.Float(indexStatName(key), ""), true
+	}
+	return stats.Field{}, false
+}
+
+// Fields returns a field for each of the keys, named after the key
+func (s *IndexStats) Fields() []stats.Field {
+	return s.fields
+}
+
+// Values returns the current values of the keys in the StatsMap of
+// the index, nil for those which are absent or not numeric
+func (s *IndexStats) Values(index bleve.Index) []interface{} {
+	statsMap := index.StatsMap()
+	rv := make([]interface{}, len(s.keys))
+	for i, key := range s.keys {
+		v := jsonpointer.Reflect(statsMap, key)
+		if _, ok := indexStatField(key, v); ok {
+			rv[i] = v
+		}
+	}
+	return rv
+}
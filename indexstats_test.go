@@ -0,0 +1,61 @@
+package blevebench
+
+import (
+	"testing"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve-bench/stats"
+)
+
+func TestIndexStats(t *testing.T) {
+	index, err := bleve.NewMemOnly(bleve.NewIndexMapping())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer index.Close()
+	err = index.Index("a", map[string]interface{}{"text": "water"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// all flattens the nested maps, keeping only the numeric values
+	all, err := NewIndexStats(index, "all")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := map[string]stats.Field{}
+	for _, f := range all.Fields() {
+		names[f.Name] = f
+	}
+	if _, ok := names["stat/index/updates"]; !ok {
+		t.Errorf("expected stat/index/updates in %v", all.Fields())
+	}
+	if _, ok := names["stat/searches"]; !ok {
+		t.Errorf("expected stat/searches in %v", all.Fields())
+	}
+	if _, ok := names["stat/index"]; ok {
+		t.Errorf("expected no field for the nested map /index")
+	}
+	if len(all.Values(index)) != len(all.Fields()) {
+		t.Errorf("expected a value for each of the %d fields", len(all.Fields()))
+	}
+
+	// listed keys are kept in order, absent ones as missing values
+	some, err := NewIndexStats(index, "/index/updates, /missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := some.Fields()
+	if len(fields) != 2 || fields[0].Name != "stat/index/updates" || fields[1].Name != "stat/missing" {
+		t.Errorf("expected fields stat/index/updates and stat/missing, got %v", fields)
+	}
+	values := some.Values(index)
+	if len(values) != 2 || values[0] != uint64(1) || values[1] != nil {
+		t.Errorf("expected values [1 <nil>], got %v", values)
+	}
+
+	_, err = NewIndexStats(index, "/index/updates,updates")
+	if err == nil {
+		t.Errorf("expected error for a key which is not a JSON pointer")
+	}
+}
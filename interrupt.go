@@ -0,0 +1,64 @@
+package blevebench
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Interrupt handles SIGINT and SIGTERM.  The first signal closes the
+// channel returned by Done, so the benchmark can stop cleanly, writing
+// its final stats and closing the index, and a second one exits
+// immediately.
+type Interrupt struct {
+	done chan struct{}
+	sig  os.Signal
+}
+
+// HandleInterrupts starts handling SIGINT and SIGTERM
+func HandleInterrupts() *Interrupt {
+	i := &Interrupt{done: make(chan struct{})}
+	c := make(chan os.Signal, 2)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		i.sig = <-c
+		log.Printf("received %v, stopping, repeat to abort", i.sig)
+		close(i.done)
+		sig := <-c
+		log.Printf("received %v, aborting", sig)
+		os.Exit(exitStatus(sig))
+	}()
+	return i
+}
+
+// Done returns a channel which is closed once a signal is received
+func (i *Interrupt) Done() <-chan struct{} {
+	return i.done
+}
+
+// Interrupted returns true once a signal has been received
+func (i *Interrupt) Interrupted() bool {
+	select {
+	case <-i.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// ExitIfInterrupted exits with the status 128 plus the signal number,
+// as a shell does, when a signal has been received.  It is intended to
+// be deferred first in main, so that it runs after everything else.
+func (i *Interrupt) ExitIfInterrupted() {
+	if i.Interrupted() {
+		os.Exit(exitStatus(i.sig))
+	}
+}
+
+func exitStatus(sig os.Signal) int {
+	if s, ok := sig.(syscall.Signal); ok {
+		return 128 + int(s)
+	}
+	return 1
+}
@@ -32,6 +32,10 @@ func BuildArticleMapping() mapping.IndexMapping {
 	articleMapping.AddFieldMappingsAt("text",
 		standardJustIndexed)
 
+	// probe, the marker terms used to measure visibility latency
+	articleMapping.AddFieldMappingsAt("probe",
+		keywordJustIndexed)
+
 	// _all (disabled)
 	disabledSection := bleve.NewDocumentDisabledMapping()
 	articleMapping.AddSubDocumentMapping("_all", disabledSection)
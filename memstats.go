@@ -0,0 +1,62 @@
+package blevebench
+
+import (
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/blevesearch/bleve-bench/stats"
+)
+
+// MemStatsFields are the fields of the values returned by
+// MemSampler.Sample
+var MemStatsFields = []stats.Field{
+	stats.Int("heap_inuse_bytes", "bytes"),
+	stats.Int("total_alloc_bytes", "bytes"),
+	stats.Int("gc_cycles", "cycles"),
+	stats.Float("gc_pause_total_ms", "ms"),
+	stats.Float("gc_pause_max_ms", "ms"),
+	stats.Int("goroutines", "goroutines"),
+}
+
+// MemSampler samples the memory and GC statistics of the go runtime,
+// the max GC pause reported covers only the GC cycles completed since
+// the previous sample
+type MemSampler struct {
+	m         sync.Mutex
+	lastNumGC uint32
+}
+
+// NewMemSampler returns a MemSampler whose first sample covers all
+// GC cycles since the process started
+func NewMemSampler() *MemSampler {
+	return &MemSampler{}
+}
+
+// Sample reads the runtime statistics, returning the values of the
+// MemStatsFields
+func (s *MemSampler) Sample() []interface{} {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+
+	s.m.Lock()
+	var maxPause uint64
+	// PauseNs is a circular buffer holding the most recent pauses
+	for gc := ms.NumGC; gc > s.lastNumGC && ms.NumGC-gc < uint32(len(ms.PauseNs)); gc-- {
+		pause := ms.PauseNs[(gc+uint32(len(ms.PauseNs))-1)%uint32(len(ms.PauseNs))]
+		if pause > maxPause {
+			maxPause = pause
+		}
+	}
+	s.lastNumGC = ms.NumGC
+	s.m.Unlock()
+
+	return []interface{}{
+		ms.HeapInuse,
+		ms.TotalAlloc,
+		uint64(ms.NumGC),
+		float64(ms.PauseTotalNs) / float64(time.Millisecond),
+		float64(maxPause) / float64(time.Millisecond),
+		runtime.NumGoroutine(),
+	}
+}
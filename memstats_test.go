@@ -0,0 +1,28 @@
+package blevebench
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestMemSampler(t *testing.T) {
+	s := NewMemSampler()
+	first := s.Sample()
+	if len(first) != len(MemStatsFields) {
+		t.Fatalf("expected %d values, got %d", len(MemStatsFields), len(first))
+	}
+
+	runtime.GC()
+	second := s.Sample()
+	if second[2].(uint64) <= first[2].(uint64) {
+		t.Errorf("expected gc cycles to increase after GC, got %v then %v", first[2], second[2])
+	}
+	if second[4].(float64) <= 0 {
+		t.Errorf("expected a max pause for the forced GC, got %v", second[4])
+	}
+
+	third := s.Sample()
+	if third[2] == second[2] && third[4].(float64) != 0 {
+		t.Errorf("expected no max pause without a new GC cycle, got %v", third[4])
+	}
+}
@@ -0,0 +1,85 @@
+package blevebench
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/search/query"
+)
+
+// NamedQuery is a query run by the benchmark, along with the
+// name used to label its output columns
+type NamedQuery struct {
+	Name  string
+	Query query.Query
+}
+
+type queryFileEntry struct {
+	Name  string          `json:"name"`
+	Query json.RawMessage `json:"query"`
+}
+
+// DefaultQueries returns the query set used when no query file is
+// specified, a single term search for "water" in the text field
+func DefaultQueries() []*NamedQuery {
+	termQuery := bleve.NewTermQuery("water")
+	termQuery.SetField("text")
+	return []*NamedQuery{
+		{
+			Name:  "water",
+			Query: termQuery,
+		},
+	}
+}
+
+// LoadQueryFile reads a JSON array of named queries from the file at
+// path, each query is expressed in the bleve query JSON syntax
+func LoadQueryFile(path string) []*NamedQuery {
+	if path == "" {
+		return DefaultQueries()
+	}
+	queryBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	queries, err := ParseQueries(queryBytes)
+	if err != nil {
+		log.Fatalf("error parsing query file '%s': %v", path, err)
+	}
+	return queries
+}
+
+// ParseQueries parses a JSON array of named queries
+func ParseQueries(queryBytes []byte) ([]*NamedQuery, error) {
+	var entries []queryFileEntry
+	err := json.Unmarshal(queryBytes, &entries)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) < 1 {
+		return nil, fmt.Errorf("must specify at least one query")
+	}
+	seen := make(map[string]struct{}, len(entries))
+	rv := make([]*NamedQuery, 0, len(entries))
+	for i, entry := range entries {
+		if entry.Name == "" {
+			return nil, fmt.Errorf("query %d has no name", i)
+		}
+		if _, exists := seen[entry.Name]; exists {
+			return nil, fmt.Errorf("duplicate query name '%s'", entry.Name)
+		}
+		seen[entry.Name] = struct{}{}
+		q, err := query.ParseQuery(entry.Query)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing query '%s': %v", entry.Name, err)
+		}
+		rv = append(rv, &NamedQuery{
+			Name:  entry.Name,
+			Query: q,
+		})
+	}
+	return rv, nil
+}
@@ -0,0 +1,95 @@
+package blevebench
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/blevesearch/bleve"
+	"github.com/dustin/go-jsonpointer"
+)
+
+// DirtyBytes returns the first CurDirtyBytes stat found in the index
+// StatsMap, the number of bytes not yet persisted by stores such as
+// moss
+func DirtyBytes(index bleve.Index) (uint64, error) {
+	statsMap := index.StatsMap()
+	keys, err := jsonpointer.ReflectListPointers(statsMap)
+	if err != nil {
+		return 0, err
+	}
+	for _, key := range keys {
+		if strings.HasSuffix(key, "CurDirtyBytes") {
+			val := jsonpointer.Reflect(statsMap, key)
+			if v, ok := val.(uint64); ok {
+				return v, nil
+			}
+		}
+	}
+	return 0, nil
+}
+
+// Quiescent reports whether the index has finished its background
+// work.  Stores reporting CurDirtyBytes, and persisting them to a lower
+// level store, must have persisted all of them, and scorch must have
+// persisted and merged up to its current root epoch.
+func Quiescent(index bleve.Index) (bool, error) {
+	return quiescent(index.StatsMap())
+}
+
+func quiescent(statsMap map[string]interface{}) (bool, error) {
+	keys, err := jsonpointer.ReflectListPointers(statsMap)
+	if err != nil {
+		return false, err
+	}
+	epochs := make(map[string]uint64)
+	for _, key := range keys {
+		v, ok := jsonpointer.Reflect(statsMap, key).(uint64)
+		if !ok {
+			continue
+		}
+		name := path.Base(key)
+		switch name {
+		case "CurDirtyBytes":
+			if v > 0 && hasLowerLevelStore(statsMap, key) {
+				return false, nil
+			}
+		case "CurRootEpoch", "LastPersistedEpoch", "LastMergedEpoch":
+			epochs[name] = v
+		}
+	}
+	if root, ok := epochs["CurRootEpoch"]; ok {
+		return epochs["LastPersistedEpoch"] >= root &&
+			epochs["LastMergedEpoch"] >= root, nil
+	}
+	return true, nil
+}
+
+// hasLowerLevelStore returns true when the moss store whose dirty bytes
+// are at key, such as /index/kv/moss/CurDirtyBytes, reports the stats
+// of a lower level store alongside, as /index/kv/kv.  Without one the
+// dirty bytes are never persisted, so never fall to zero.
+func hasLowerLevelStore(statsMap map[string]interface{}, key string) bool {
+	return jsonpointer.Reflect(statsMap, path.Join(path.Dir(path.Dir(key)), "kv")) != nil
+}
+
+// WaitQuiescent polls the index every interval until it is quiescent,
+// returning the time spent waiting.  An error is returned if the
+// index is still busy after timeout.
+func WaitQuiescent(index bleve.Index, interval, timeout time.Duration) (time.Duration, error) {
+	start := time.Now()
+	for {
+		quiet, err := Quiescent(index)
+		if err != nil {
+			return time.Since(start), err
+		}
+		if quiet {
+			return time.Since(start), nil
+		}
+		if time.Since(start) > timeout {
+			return time.Since(start), fmt.Errorf("index not quiescent after %v", timeout)
+		}
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,53 @@
+package blevebench
+
+import (
+	"testing"
+)
+
+func TestQuiescent(t *testing.T) {
+	scorch := func(root, persisted, merged uint64) map[string]interface{} {
+		return map[string]interface{}{
+			"index": map[string]interface{}{
+				"CurRootEpoch":       root,
+				"LastPersistedEpoch": persisted,
+				"LastMergedEpoch":    merged,
+			},
+		}
+	}
+	moss := func(dirty uint64, lowerLevel bool) map[string]interface{} {
+		kv := map[string]interface{}{
+			"moss": map[string]interface{}{
+				"CurDirtyBytes": dirty,
+			},
+		}
+		if lowerLevel {
+			kv["kv"] = map[string]interface{}{}
+		}
+		return map[string]interface{}{
+			"index": map[string]interface{}{
+				"kv": kv,
+			},
+		}
+	}
+	for _, test := range []struct {
+		name     string
+		statsMap map[string]interface{}
+		expected bool
+	}{
+		{"scorch persisted and merged", scorch(5, 5, 5), true},
+		{"scorch persisting", scorch(5, 4, 5), false},
+		{"scorch merging", scorch(5, 5, 4), false},
+		{"moss with lower level store, dirty", moss(100, true), false},
+		{"moss with lower level store, persisted", moss(0, true), true},
+		{"moss without lower level store", moss(100, false), true},
+		{"no background work", map[string]interface{}{"searches": uint64(0)}, true},
+	} {
+		quiet, err := quiescent(test.statsMap)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if quiet != test.expected {
+			t.Errorf("%s: expected quiescent %t, got %t", test.name, test.expected, quiet)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package blevebench
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket, refilled at a fixed rate of tokens
+// per second up to its burst size.  Taking more tokens than are
+// available puts the bucket into debt, so a request larger than the
+// burst size is allowed, the wait is just longer.
+type RateLimiter struct {
+	m      sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter returns a full RateLimiter
+func NewRateLimiter(rate, burst float64) *RateLimiter {
+	return &RateLimiter{
+		rate:   rate,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+// Wait takes n tokens, blocking until they are available or done is
+// closed, returning false if done was closed first
+func (r *RateLimiter) Wait(n float64, done <-chan struct{}) bool {
+	wait := r.reserve(n, time.Now())
+	if wait <= 0 {
+		return true
+	}
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-done:
+		return false
+	}
+}
+
+// reserve takes n tokens at the time now, returning how long until
+// they are available
+func (r *RateLimiter) reserve(n float64, now time.Time) time.Duration {
+	r.m.Lock()
+	defer r.m.Unlock()
+	if now.After(r.last) {
+		r.tokens += now.Sub(r.last).Seconds() * r.rate
+		if r.tokens > r.burst {
+			r.tokens = r.burst
+		}
+		r.last = now
+	}
+	r.tokens -= n
+	if r.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-r.tokens / r.rate * float64(time.Second))
+}
@@ -0,0 +1,46 @@
+package blevebench
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiterReserve(t *testing.T) {
+	r := NewRateLimiter(100, 10)
+	now := r.last
+
+	// the burst is available immediately
+	if wait := r.reserve(10, now); wait != 0 {
+		t.Errorf("expected no wait for the burst, got %v", wait)
+	}
+	// then tokens arrive at the rate, 50 tokens take half a second
+	if wait := r.reserve(50, now); wait != 500*time.Millisecond {
+		t.Errorf("expected wait 500ms, got %v", wait)
+	}
+	// once the debt is repaid, refilling stops at the burst size
+	now = now.Add(10 * time.Second)
+	if wait := r.reserve(10, now); wait != 0 {
+		t.Errorf("expected no wait after refill, got %v", wait)
+	}
+	if wait := r.reserve(1, now); wait != 10*time.Millisecond {
+		t.Errorf("expected wait 10ms beyond the burst, got %v", wait)
+	}
+}
+
+func TestRateLimiterWaitDone(t *testing.T) {
+	r := NewRateLimiter(1, 1)
+	done := make(chan struct{})
+
+	if !r.Wait(1, done) {
+		t.Errorf("expected the burst to be taken without waiting")
+	}
+	// the next token is a minute away, closing done ends the wait
+	close(done)
+	start := time.Now()
+	if r.Wait(60, done) {
+		t.Errorf("expected the wait to end when done was closed")
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("expected the wait to end promptly, took %v", time.Since(start))
+	}
+}
@@ -0,0 +1,30 @@
+package blevebench
+
+import (
+	"time"
+
+	"github.com/blevesearch/bleve"
+)
+
+// Reopen closes the index and opens the one at path again using the
+// runtime config, returning the reopened index and the time taken to
+// open it, which excludes the time taken to close it
+func Reopen(index bleve.Index, path string, runtimeConfig map[string]interface{}) (bleve.Index, time.Duration, error) {
+	err := index.Close()
+	if err != nil {
+		return nil, 0, err
+	}
+	start := time.Now()
+	index, err = bleve.OpenUsing(path, runtimeConfig)
+	if err != nil {
+		return nil, 0, err
+	}
+	return index, time.Since(start), nil
+}
+
+// TimeFirstQuery runs the query once, returning the time taken
+func TimeFirstQuery(index bleve.Index, q *NamedQuery) (time.Duration, error) {
+	start := time.Now()
+	_, err := index.Search(bleve.NewSearchRequest(q.Query))
+	return time.Since(start), err
+}
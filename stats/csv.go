@@ -0,0 +1,73 @@
+//  Copyright (c) 2019 Couchbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stats
+
+import (
+	"encoding/csv"
+	"io"
+	"sync"
+)
+
+type csvEmitter struct {
+	m      sync.Mutex
+	w      *csv.Writer
+	fields []Field
+}
+
+func newCSVEmitter(w io.Writer, fields []Field) *csvEmitter {
+	return &csvEmitter{
+		w:      csv.NewWriter(w),
+		fields: fields,
+	}
+}
+
+func (e *csvEmitter) Fields() []Field {
+	return e.fields
+}
+
+func (e *csvEmitter) WriteHeader() error {
+	record := make([]string, len(e.fields))
+	for i, f := range e.fields {
+		record[i] = f.Name
+	}
+	return e.write(record)
+}
+
+func (e *csvEmitter) WriteRow(values []interface{}) error {
+	err := checkRow(e.fields, values)
+	if err != nil {
+		return err
+	}
+	record := make([]string, len(values))
+	for i, v := range values {
+		// missing float values keep their NaN/Inf form in CSV
+		record[i], _, err = formatValue(e.fields[i], v)
+		if err != nil {
+			return err
+		}
+	}
+	return e.write(record)
+}
+
+func (e *csvEmitter) write(record []string) error {
+	e.m.Lock()
+	defer e.m.Unlock()
+	err := e.w.Write(record)
+	if err != nil {
+		return err
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
@@ -0,0 +1,100 @@
+//  Copyright (c) 2019 Couchbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stats
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+type jsonHeader struct {
+	SchemaVersion int     `json:"schema_version"`
+	Fields        []Field `json:"fields"`
+}
+
+type jsonEmitter struct {
+	m      sync.Mutex
+	w      io.Writer
+	fields []Field
+}
+
+func newJSONEmitter(w io.Writer, fields []Field) *jsonEmitter {
+	return &jsonEmitter{
+		w:      w,
+		fields: fields,
+	}
+}
+
+func (e *jsonEmitter) Fields() []Field {
+	return e.fields
+}
+
+// WriteHeader writes a record describing the schema version and the
+// fields present in every following row
+func (e *jsonEmitter) WriteHeader() error {
+	buf, err := json.Marshal(jsonHeader{
+		SchemaVersion: SchemaVersion,
+		Fields:        e.fields,
+	})
+	if err != nil {
+		return err
+	}
+	return e.write(append(buf, '\n'))
+}
+
+// WriteRow writes the values as a single JSON object, keyed by field
+// name in the order the fields were declared.  Missing values are
+// written as null.
+func (e *jsonEmitter) WriteRow(values []interface{}) error {
+	err := checkRow(e.fields, values)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, v := range values {
+		f := e.fields[i]
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(f.Name)
+		buf.Write(name)
+		buf.WriteByte(':')
+		s, missing, err := formatValue(f, v)
+		if err != nil {
+			return err
+		}
+		switch {
+		case missing:
+			buf.WriteString("null")
+		case f.Type == TypeInt || f.Type == TypeFloat:
+			buf.WriteString(s)
+		default:
+			str, _ := json.Marshal(s)
+			buf.Write(str)
+		}
+	}
+	buf.WriteString("}\n")
+	return e.write(buf.Bytes())
+}
+
+func (e *jsonEmitter) write(buf []byte) error {
+	e.m.Lock()
+	defer e.m.Unlock()
+	_, err := e.w.Write(buf)
+	return err
+}
@@ -0,0 +1,166 @@
+//  Copyright (c) 2019 Couchbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package stats emits the rows of statistics produced by the benchmark
+// commands, as either CSV or JSON lines, from a declared set of typed
+// fields.
+package stats
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+)
+
+// SchemaVersion identifies the layout of the records emitted, it is
+// incremented whenever that layout changes incompatibly
+const SchemaVersion = 1
+
+// Type is the type of the values in a field
+type Type string
+
+const (
+	TypeInt    Type = "int"
+	TypeFloat  Type = "float"
+	TypeString Type = "string"
+	TypeTime   Type = "time"
+)
+
+// Field describes one column of the emitted rows
+type Field struct {
+	Name string `json:"name"`
+	Type Type   `json:"type"`
+	Unit string `json:"unit,omitempty"`
+}
+
+// Int returns a field holding integer values measured in unit
+func Int(name, unit string) Field {
+	return Field{Name: name, Type: TypeInt, Unit: unit}
+}
+
+// Float returns a field holding floating point values measured in unit
+func Float(name, unit string) Field {
+	return Field{Name: name, Type: TypeFloat, Unit: unit}
+}
+
+// String returns a field holding string values
+func String(name string) Field {
+	return Field{Name: name, Type: TypeString}
+}
+
+// Time returns a field holding time.Time values
+func Time(name string) Field {
+	return Field{Name: name, Type: TypeTime}
+}
+
+// Emitter writes a header followed by rows of values, one value for
+// each of its fields, in the order the fields were declared
+type Emitter interface {
+	Fields() []Field
+	WriteHeader() error
+	WriteRow(values []interface{}) error
+}
+
+// Formats lists the supported output formats
+var Formats = []string{"csv", "json"}
+
+// NewEmitter returns an Emitter writing the fields to w using the
+// named format, either "csv" or "json" (JSON lines)
+func NewEmitter(w io.Writer, format string, fields []Field) (Emitter, error) {
+	seen := make(map[string]struct{}, len(fields))
+	for _, f := range fields {
+		if _, exists := seen[f.Name]; exists {
+			return nil, fmt.Errorf("duplicate field '%s'", f.Name)
+		}
+		seen[f.Name] = struct{}{}
+	}
+	switch format {
+	case "csv", "":
+		return newCSVEmitter(w, fields), nil
+	case "json":
+		return newJSONEmitter(w, fields), nil
+	}
+	return nil, fmt.Errorf("unknown stats format '%s', must be one of %v", format, Formats)
+}
+
+func checkRow(fields []Field, values []interface{}) error {
+	if len(values) != len(fields) {
+		return fmt.Errorf("row has %d values, expected %d", len(values), len(fields))
+	}
+	return nil
+}
+
+func toInt(v interface{}) (int64, bool) {
+	switch v := v.(type) {
+	case int:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case uint64:
+		return int64(v), true
+	case time.Duration:
+		return int64(v), true
+	}
+	return 0, false
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	}
+	if i, ok := toInt(v); ok {
+		return float64(i), true
+	}
+	return 0, false
+}
+
+// formatValue returns the textual form of v for the field f, along
+// with whether the value is missing (NaN, Inf or nil)
+func formatValue(f Field, v interface{}) (string, bool, error) {
+	if v == nil {
+		return "", true, nil
+	}
+	switch f.Type {
+	case TypeInt:
+		if i, ok := toInt(v); ok {
+			return strconv.FormatInt(i, 10), false, nil
+		}
+	case TypeFloat:
+		if fv, ok := toFloat(v); ok {
+			missing := math.IsNaN(fv) || math.IsInf(fv, 0)
+			return fmt.Sprintf("%f", fv), missing, nil
+		}
+	case TypeString:
+		if s, ok := v.(string); ok {
+			return s, false, nil
+		}
+		return fmt.Sprintf("%v", v), false, nil
+	case TypeTime:
+		if t, ok := v.(time.Time); ok {
+			return t.Format(time.RFC3339), false, nil
+		}
+	}
+	return "", false, fmt.Errorf("value %v (%T) is not valid for %s field '%s'", v, v, f.Type, f.Name)
+}
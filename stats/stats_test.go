@@ -0,0 +1,105 @@
+//  Copyright (c) 2019 Couchbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stats
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+)
+
+var testFields = []Field{
+	Time("date"),
+	Int("docs", "docs"),
+	Float("rate", "MB/s"),
+	String("phase"),
+}
+
+var testDate = time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func TestCSVEmitter(t *testing.T) {
+	var buf bytes.Buffer
+	e, err := NewEmitter(&buf, "csv", testFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = e.WriteHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = e.WriteRow([]interface{}{testDate, uint64(10), 1.5, "indexing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "date,docs,rate,phase\n2019-01-02T03:04:05Z,10,1.500000,indexing\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestJSONEmitter(t *testing.T) {
+	var buf bytes.Buffer
+	e, err := NewEmitter(&buf, "json", testFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = e.WriteHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = e.WriteRow([]interface{}{testDate, 10, math.NaN(), "indexing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(lines))
+	}
+	var header jsonHeader
+	err = json.Unmarshal(lines[0], &header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.SchemaVersion != SchemaVersion || len(header.Fields) != len(testFields) {
+		t.Errorf("unexpected header %s", lines[0])
+	}
+	expected := `{"date":"2019-01-02T03:04:05Z","docs":10,"rate":null,"phase":"indexing"}`
+	if string(lines[1]) != expected {
+		t.Errorf("expected %s, got %s", expected, lines[1])
+	}
+}
+
+func TestEmitterRejectsBadRows(t *testing.T) {
+	var buf bytes.Buffer
+	e, err := NewEmitter(&buf, "csv", testFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = e.WriteRow([]interface{}{testDate, 10})
+	if err == nil {
+		t.Errorf("expected error for short row")
+	}
+	err = e.WriteRow([]interface{}{testDate, "ten", 1.0, "indexing"})
+	if err == nil {
+		t.Errorf("expected error for mistyped value")
+	}
+	_, err = NewEmitter(&buf, "xml", testFields)
+	if err == nil {
+		t.Errorf("expected error for unknown format")
+	}
+}
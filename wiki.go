@@ -52,3 +52,26 @@ func (w *WikiReader) Next() (*Article, error) {
 func (w *WikiReader) Close() error {
 	return w.file.Close()
 }
+
+// AverageArticleBytes returns the average plaintext size of the first
+// n articles in the line file at path
+func AverageArticleBytes(path string, n int) (float64, error) {
+	w, err := NewWikiReader(path)
+	if err != nil {
+		return 0, err
+	}
+	defer w.Close()
+	var total, count int
+	for count < n {
+		a, err := w.Next()
+		if err != nil {
+			break
+		}
+		total += len(a.Title) + len(a.Text)
+		count++
+	}
+	if count == 0 {
+		return 0, fmt.Errorf("no articles found in '%s'", path)
+	}
+	return float64(total) / float64(count), nil
+}